@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	chartMaxCoins      = 6  // 仓位图最多显示的币种数，按期间内最大名义价值排序
+	chartFillsMaxPages = 20 // 盈亏图最多拉取的成交页数
+)
+
+func handleChartCommand(chatID, msgText string) {
+	usage := "用法: /chart <地址|名称|#标签> [24h|7d|30d]"
+	parts := strings.Fields(msgText)
+	if len(parts) < 2 {
+		sendMessage(chatID, usage)
+		return
+	}
+	period := "24h"
+	selectorParts := parts[1:]
+	if _, exists := historyPeriods[selectorParts[len(selectorParts)-1]]; exists && len(selectorParts) > 1 {
+		period = selectorParts[len(selectorParts)-1]
+		selectorParts = selectorParts[:len(selectorParts)-1]
+	}
+	query := strings.Join(selectorParts, " ")
+
+	targets, ok := statusTargets(chatID, query)
+	if !ok || len(targets) == 0 {
+		sendMessage(chatID, fmt.Sprintf("未找到名称或地址为 %s 的订阅。\n%s", query, usage))
+		return
+	}
+	telegramChatID, err := strconv.ParseInt(chatID, 10, 64)
+	if err != nil {
+		log.Printf("无效的Chat ID %s: %v", chatID, err)
+		return
+	}
+
+	go func() {
+		since := time.Now().Add(-historyPeriods[period])
+		for _, wallet := range targets {
+			snapshots, err := loadAccountSnapshots(wallet.Address, since)
+			if err != nil {
+				log.Printf("读取账户快照失败 %s: %v", wallet.Address, err)
+				sendMessage(chatID, "读取历史数据失败，请稍后重试。")
+				return
+			}
+			if len(snapshots) < 2 {
+				sendMessage(chatID, fmt.Sprintf("%s (%s) 在 %s 内的快照不足，暂时无法生成图表。", wallet.Name, shortenAddress(wallet.Address), period))
+				continue
+			}
+
+			realized, err := realizedPnlSeries(wallet.Address, since)
+			if err != nil {
+				log.Printf("获取 %s 成交记录失败: %v", wallet.Address, err)
+			}
+			charts := []struct {
+				name    string
+				caption string
+				chart   lineChart
+			}{
+				{"value", "💰 账户价值", accountValueChart(snapshots)},
+				{"positions", "📊 各币种仓位名义价值（空头为负）", positionChart(snapshots)},
+				{"pnl", "💹 已实现与未实现盈亏", pnlChart(snapshots, realized)},
+			}
+			for _, c := range charts {
+				data, err := c.chart.RenderPNG()
+				if err != nil {
+					log.Printf("生成图表失败 %s: %v", wallet.Address, err)
+					continue
+				}
+				photo := tgbotapi.NewPhoto(telegramChatID, tgbotapi.FileBytes{Name: c.name + ".png", Bytes: data})
+				photo.Caption = fmt.Sprintf("%s - %s (%s, %s)", c.caption, wallet.Name, shortenAddress(wallet.Address), period)
+				sendChattable(chatID, photo, nil)
+			}
+		}
+	}()
+}
+
+func accountValueChart(snapshots []accountSnapshot) lineChart {
+	series := chartSeries{Name: "Account value (USD)"}
+	for _, snapshot := range snapshots {
+		series.Points = append(series.Points, chartPoint{Time: snapshot.Time, Value: snapshot.AccountValue})
+	}
+	return lineChart{Title: "Account value", Series: []chartSeries{series}}
+}
+
+// 每个币种一条曲线，未持仓的时刻按 0 绘制
+func positionChart(snapshots []accountSnapshot) lineChart {
+	peak := make(map[string]float64)
+	for _, snapshot := range snapshots {
+		for _, position := range snapshot.Positions {
+			peak[position.Coin] = math.Max(peak[position.Coin], math.Abs(position.Value))
+		}
+	}
+	coins := make([]string, 0, len(peak))
+	for coin := range peak {
+		coins = append(coins, coin)
+	}
+	sort.Slice(coins, func(i, j int) bool {
+		return peak[coins[i]] > peak[coins[j]]
+	})
+	if len(coins) > chartMaxCoins {
+		coins = coins[:chartMaxCoins]
+	}
+
+	chart := lineChart{Title: "Position notional by coin (USD, short < 0)", IncludeZero: true}
+	for _, coin := range coins {
+		series := chartSeries{Name: coin}
+		for _, snapshot := range snapshots {
+			value := 0.0
+			for _, position := range snapshot.Positions {
+				if position.Coin == coin {
+					value = position.Value
+				}
+			}
+			series.Points = append(series.Points, chartPoint{Time: snapshot.Time, Value: value})
+		}
+		chart.Series = append(chart.Series, series)
+	}
+	if len(chart.Series) == 0 {
+		// 期间内没有持仓时画一条 0 线
+		series := chartSeries{Name: "No positions"}
+		for _, snapshot := range snapshots {
+			series.Points = append(series.Points, chartPoint{Time: snapshot.Time})
+		}
+		chart.Series = append(chart.Series, series)
+	}
+	return chart
+}
+
+func pnlChart(snapshots []accountSnapshot, realized []chartPoint) lineChart {
+	unrealized := chartSeries{Name: "Unrealized PnL"}
+	for _, snapshot := range snapshots {
+		unrealized.Points = append(unrealized.Points, chartPoint{Time: snapshot.Time, Value: snapshot.UnrealizedPnl})
+	}
+	chart := lineChart{Title: "Realized vs unrealized PnL (USD)", Series: []chartSeries{unrealized}, IncludeZero: true}
+	if len(realized) > 0 {
+		chart.Series = append(chart.Series, chartSeries{Name: "Realized PnL (net of fees)", Points: realized})
+	}
+	return chart
+}
+
+// 根据期间内的成交累计已实现盈亏（平仓盈亏减手续费），从 0 开始，按阶梯绘制。
+// 成交超过 chartFillsMaxPages 页时只画到已取得的最后一笔
+func realizedPnlSeries(address string, since time.Time) ([]chartPoint, error) {
+	fills, _, complete, err := fetchFillsAfter(address, FillCursor{Time: since.UnixMilli()}, 0, chartFillsMaxPages)
+	if err != nil {
+		return nil, err
+	}
+
+	points := []chartPoint{{Time: since}}
+	total := 0.0
+	for _, fill := range fills {
+		closedPnl, _ := strconv.ParseFloat(fill.ClosedPnl, 64)
+		fee, _ := strconv.ParseFloat(fill.Fee, 64)
+		fillTime := time.UnixMilli(fill.Time)
+		points = append(points, chartPoint{Time: fillTime, Value: total})
+		total += closedPnl - fee
+		points = append(points, chartPoint{Time: fillTime, Value: total})
+	}
+	if !complete {
+		return points, nil
+	}
+	return append(points, chartPoint{Time: time.Now(), Value: total}), nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"testing"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+func TestLineChartRenderPNG(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	charts := map[string]lineChart{
+		"two series": {Title: "PnL", IncludeZero: true, Series: []chartSeries{
+			{Name: "a", Points: []chartPoint{{start, 100}, {start.Add(time.Hour), -50}, {start.Add(2 * time.Hour), 1500}}},
+			{Name: "b", Points: []chartPoint{{start, 0}, {start.Add(2 * time.Hour), 20}}},
+		}},
+		"single point": {Title: "Value", Series: []chartSeries{{Name: "a", Points: []chartPoint{{start, 1000}}}}},
+		"flat":         {Title: "Value", Series: []chartSeries{{Name: "a", Points: []chartPoint{{start, 0}, {start.Add(time.Hour), 0}}}}},
+	}
+	for name, chart := range charts {
+		t.Run(name, func(t *testing.T) {
+			data, err := chart.RenderPNG()
+			if err != nil {
+				t.Fatalf("RenderPNG: %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if size := img.Bounds().Size(); size.X != 900 || size.Y != 500 {
+				t.Errorf("size = %v, want 900x500", size)
+			}
+		})
+	}
+
+	if _, err := (lineChart{Title: "empty"}).RenderPNG(); err == nil {
+		t.Error("expected an error for a chart without points")
+	}
+}
+
+// 已实现盈亏曲线需要翻页取得期间内的全部成交
+func TestRealizedPnlSeriesPages(t *testing.T) {
+	since := time.Now().Add(-24 * time.Hour)
+	var fills []hyperliquid.Fill
+	for i := 0; i < 2*fillsPageSize+100; i++ {
+		fill := testFill(since.UnixMilli()+int64(i/2), int64(i+1))
+		fill.ClosedPnl = "1"
+		fill.Fee = "0.25"
+		fills = append(fills, fill)
+	}
+	startFakeFillsServer(t, fills)
+
+	points, err := realizedPnlSeries("0xabc", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := float64(len(fills)) * 0.75
+	if last := points[len(points)-1].Value; math.Abs(last-want) > 1e-6 {
+		t.Errorf("final realized PnL = %.2f, want %.2f", last, want)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ChannelDingTalk = "dingtalk"
+
+	dingTalkWebhookPrefix = "https://oapi.dingtalk.com/robot/send?access_token="
+	dingTalkMaxText       = 20000
+)
+
+// 钉钉 Markdown 消息的文字颜色
+var dingTalkColors = map[int]string{
+	discordColorGreen:  "#2ecc71",
+	discordColorRed:    "#e74c3c",
+	discordColorOrange: "#e67e22",
+}
+
+type dingTalkMarkdown struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+type dingTalkPayload struct {
+	MsgType  string           `json:"msgtype"`
+	Markdown dingTalkMarkdown `json:"markdown"`
+}
+
+// DingTalkNotifier 通过钉钉群机器人发送 Markdown 消息，target 为 Webhook 地址，
+// 订阅的 Secret 为机器人“加签”密钥（SEC 开头），不为空时在地址上附加签名
+type DingTalkNotifier struct {
+	HTTPClient *http.Client
+}
+
+func NewDingTalkNotifier() *DingTalkNotifier {
+	return &DingTalkNotifier{HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (d *DingTalkNotifier) Notify(target string, notification Notification) error {
+	webhookURL := target
+	if secret := notification.Wallet.Secret; secret != "" {
+		timestamp := time.Now().UnixMilli()
+		webhookURL += fmt.Sprintf("&timestamp=%d&sign=%s", timestamp, url.QueryEscape(dingTalkSign(secret, timestamp)))
+	}
+
+	title := notification.Title
+	if title == "" {
+		title = "HyperLiquid提醒"
+	}
+	payload := dingTalkPayload{MsgType: "markdown", Markdown: dingTalkMarkdown{
+		Title: fmt.Sprintf("%s - %s", title, notification.Wallet.Name),
+		Text:  truncateText(dingTalkText(title, notification), dingTalkMaxText),
+	}}
+	return postBotMessage(d.HTTPClient, webhookURL, payload, "钉钉")
+}
+
+// 钉钉的 Markdown 需要空行才会换行，所以每行之间用两个换行符
+func dingTalkText(title string, notification Notification) string {
+	wallet := notification.Wallet
+	lines := []string{
+		"### " + title,
+		fmt.Sprintf("**账户**: %s (%s)", wallet.Name, shortenAddress(wallet.Address)),
+	}
+	for _, section := range markdownSections(notification) {
+		heading := "**" + section.Title + "**"
+		if color, exists := dingTalkColors[section.Color]; exists {
+			heading = fmt.Sprintf("<font color=\"%s\">%s</font>", color, heading)
+		}
+		lines = append(lines, "---", heading)
+		lines = append(lines, section.Lines...)
+	}
+	return strings.Join(lines, "\n\n")
+}
+
+// 钉钉加签：以密钥对 "时间戳\n密钥" 做 HMAC-SHA256，再做 Base64
+func dingTalkSign(secret string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d\n%s", timestamp, secret)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func isValidDingTalkWebhook(url string) bool {
+	return strings.HasPrefix(url, dingTalkWebhookPrefix)
+}
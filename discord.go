@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	discordWebhookPrefix  = "https://discord.com/api/webhooks/"
+	discordMaxEmbeds      = 10
+	discordMaxDescription = 4096
+)
+
+// Discord 嵌入消息的颜色
+const (
+	discordColorGreen  = 0x2ecc71
+	discordColorRed    = 0xe74c3c
+	discordColorOrange = 0xe67e22
+	discordColorBlue   = 0x3498db
+)
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+}
+
+type discordWebhookPayload struct {
+	Username string         `json:"username,omitempty"`
+	Content  string         `json:"content,omitempty"`
+	Embeds   []discordEmbed `json:"embeds,omitempty"`
+}
+
+// DiscordNotifier 通过频道 Webhook 发送嵌入消息，target 为 Webhook 地址
+type DiscordNotifier struct {
+	HTTPClient *http.Client
+}
+
+func NewDiscordNotifier() *DiscordNotifier {
+	return &DiscordNotifier{HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (d *DiscordNotifier) Notify(target string, notification Notification) error {
+	embeds := discordEmbeds(notification)
+	for start := 0; start < len(embeds); start += discordMaxEmbeds {
+		end := start + discordMaxEmbeds
+		if end > len(embeds) {
+			end = len(embeds)
+		}
+		payload := discordWebhookPayload{Username: "Position Monitor", Embeds: embeds[start:end]}
+		if err := d.post(target, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DiscordNotifier) post(webhookURL string, payload discordWebhookPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("转换JSON时出错: %v", err)
+	}
+	resp, err := d.HTTPClient.Post(webhookURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("发送Discord消息失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Discord返回状态码 %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// 有事件时每个事件一个嵌入，否则整段文本作为一个嵌入
+func discordEmbeds(notification Notification) []discordEmbed {
+	wallet := notification.Wallet
+	header := fmt.Sprintf("%s (%s)", wallet.Name, shortenAddress(wallet.Address))
+
+	if notification.Changes == nil || notification.Changes.Empty() {
+		title := notification.Title
+		if title == "" {
+			title = header
+		}
+		return []discordEmbed{{
+			Title:       title,
+			Description: truncateText(notification.Text, discordMaxDescription),
+			Color:       discordColorBlue,
+			Timestamp:   time.Now().Format(time.RFC3339),
+		}}
+	}
+
+	changes := notification.Changes
+	timestamp := changes.Time.Format(time.RFC3339)
+	var embeds []discordEmbed
+	for _, event := range changes.PositionEvents {
+		lines := strings.Split(strings.TrimSpace(renderPositionEvent(event)), "\n")
+		embeds = append(embeds, discordEmbed{
+			Title:       lines[0],
+			Description: strings.TrimSpace(strings.Join(lines[1:], "\n")),
+			Color:       positionEventColor(event.Type),
+			Fields:      []discordEmbedField{{Name: "账户", Value: header}},
+			Timestamp:   timestamp,
+		})
+	}
+	if len(changes.AccountEvents) > 0 {
+		embed := discordEmbed{Title: "账户变化 - " + header, Color: discordColorBlue, Timestamp: timestamp}
+		for _, event := range changes.AccountEvents {
+			lines := strings.Split(strings.TrimSpace(renderAccountEvent(event)), "\n")
+			embed.Fields = append(embed.Fields, discordEmbedField{
+				Name:  lines[0],
+				Value: strings.TrimSpace(strings.Join(lines[1:], "\n")),
+			})
+		}
+		embeds = append(embeds, embed)
+	}
+	return embeds
+}
+
+func positionEventColor(eventType PositionEventType) int {
+	switch eventType {
+	case PositionOpen, PositionIncrease:
+		return discordColorGreen
+	case PositionClose, PositionDecrease:
+		return discordColorRed
+	case PositionFlip:
+		return discordColorOrange
+	}
+	return discordColorBlue
+}
+
+func isValidDiscordWebhook(url string) bool {
+	return strings.HasPrefix(url, discordWebhookPrefix)
+}
+
+// 按字符截断，避免截断多字节字符
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+const (
+	ChannelEmail = "email"
+
+	DigestImmediate = ""
+	DigestHourly    = "hourly"
+	DigestDaily     = "daily"
+)
+
+// SMTPConfig 邮件通知的发信配置，TLS 为 true 时使用隐式 TLS（通常为 465 端口），否则在服务器支持时使用 STARTTLS
+type SMTPConfig struct {
+	Host            string `json:"host"`
+	Port            int    `json:"port"`
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	From            string `json:"from"`
+	TLS             bool   `json:"tls"`
+	DailyDigestHour int    `json:"dailyDigestHour"` // 每日摘要发送的本地小时
+}
+
+// EmailNotifier 发送 HTML 邮件，订阅设置了摘要周期时先写入队列，由 runEmailDigests 定时汇总发送
+type EmailNotifier struct {
+	Config SMTPConfig
+	// Timeout 限制连接和整个 SMTP 会话的时长
+	Timeout time.Duration
+	// SendMail 默认通过 Config 中的服务器发送，可替换为本地 SMTP 替身
+	SendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewEmailNotifier(cfg SMTPConfig) *EmailNotifier {
+	n := &EmailNotifier{Config: cfg, Timeout: 30 * time.Second}
+	n.SendMail = n.defaultSendMail
+	return n
+}
+
+type emailItem struct {
+	Time  string
+	Name  string
+	Title string
+	Text  string
+}
+
+type emailAccount struct {
+	Name      string
+	Address   string
+	Summary   AccountSummary
+	Positions []positionView
+}
+
+type emailData struct {
+	Title       string
+	GeneratedAt string
+	Items       []emailItem
+	Accounts    []emailAccount
+}
+
+var emailTemplate = template.Must(template.New("email").Funcs(template.FuncMap{
+	"usd": func(v float64) string { return fmt.Sprintf("$%.2f", v) },
+	"num": func(v float64) string { return strconv.FormatFloat(v, 'f', 5, 64) },
+	"pct": func(v float64) string { return fmt.Sprintf("%.2f%%", v) },
+}).Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif; color: #222;">
+<h2>{{.Title}}</h2>
+<p style="color: #888;">{{.GeneratedAt}}</p>
+{{range .Items}}
+<div style="margin-bottom: 16px;">
+  <h4 style="margin: 0;">{{.Title}} - {{.Name}} <span style="color: #888; font-weight: normal;">{{.Time}}</span></h4>
+  <pre style="background: #f6f8fa; padding: 8px; white-space: pre-wrap;">{{.Text}}</pre>
+</div>
+{{end}}
+{{range .Accounts}}
+<h3>{{.Name}} <span style="color: #888; font-weight: normal;">{{.Address}}</span></h3>
+<p>账户价值: {{usd .Summary.AccountValue}} &nbsp; 可提取金额: {{usd .Summary.Withdrawable}} &nbsp; 已用保证金: {{usd .Summary.TotalMarginUsed}}</p>
+{{if .Positions}}
+<table cellpadding="6" style="border-collapse: collapse; border: 1px solid #ddd;">
+  <tr style="background: #f0f0f0;"><th>币种</th><th>方向</th><th>仓位大小</th><th>仓位价值</th><th>入场价格</th><th>杠杆</th><th>盈亏</th><th>强平价格</th><th>已用保证金</th></tr>
+  {{range .Positions}}
+  <tr>
+    <td>{{.Coin}}</td><td>{{.Direction}}</td><td>{{num .Size}}</td><td>{{usd .Value}}</td><td>{{usd .EntryPx}}</td>
+    <td>{{.Leverage}}x ({{.LeverageType}})</td>
+    <td style="color: {{if ge .UnrealizedPnl 0.0}}#2e7d32{{else}}#c62828{{end}};">{{usd .UnrealizedPnl}} ({{pct .RoiPercent}})</td>
+    <td>{{usd .LiquidationPx}}</td><td>{{usd .MarginUsed}}</td>
+  </tr>
+  {{end}}
+</table>
+{{else}}
+<p>没有找到开放的持仓。</p>
+{{end}}
+{{end}}
+</body></html>`))
+
+func (n *EmailNotifier) Notify(target string, notification Notification) error {
+	wallet := notification.Wallet
+	if wallet.Digest != DigestImmediate {
+		return queueEmailDigest(target, wallet, notification)
+	}
+
+	data := emailData{
+		Title:       notification.Title,
+		GeneratedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Items: []emailItem{{
+			Time:  time.Now().Format("2006-01-02 15:04:05"),
+			Name:  wallet.Name,
+			Title: notification.Title,
+			Text:  notification.Text,
+		}},
+		Accounts: emailAccounts([]WalletConfig{wallet}),
+	}
+	subject := fmt.Sprintf("%s - %s", notification.Title, wallet.Name)
+	return n.send(target, subject, data)
+}
+
+func (n *EmailNotifier) send(to, subject string, data emailData) error {
+	var body bytes.Buffer
+	if err := emailTemplate.Execute(&body, data); err != nil {
+		return fmt.Errorf("渲染邮件失败: %v", err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.Config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+
+	addr := net.JoinHostPort(n.Config.Host, strconv.Itoa(n.Config.Port))
+	var auth smtp.Auth
+	if n.Config.Username != "" {
+		auth = smtp.PlainAuth("", n.Config.Username, n.Config.Password, n.Config.Host)
+	}
+	from := n.Config.From
+	if parsed, err := mail.ParseAddress(from); err == nil {
+		from = parsed.Address
+	}
+	if err := n.SendMail(addr, auth, from, []string{to}, msg.Bytes()); err != nil {
+		return fmt.Errorf("发送邮件失败: %v", err)
+	}
+	return nil
+}
+
+// 自行建立连接，连接和整个会话都有超时，SMTP 服务器无响应时不会一直阻塞。
+// 未使用隐式 TLS 时，服务器支持的情况下升级为 STARTTLS，与 smtp.SendMail 一致
+func (n *EmailNotifier) defaultSendMail(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	dialer := &net.Dialer{Timeout: n.Timeout}
+	tlsConfig := &tls.Config{ServerName: n.Config.Host}
+	var conn net.Conn
+	var err error
+	if n.Config.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(n.Timeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, n.Config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !n.Config.TLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("SMTP服务器不支持身份验证")
+		}
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// 用最近一次获取的状态生成账户概览
+func emailAccounts(subscriptions []WalletConfig) []emailAccount {
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+
+	var accounts []emailAccount
+	seen := make(map[string]bool)
+	for _, wallet := range subscriptions {
+		if seen[wallet.Address] {
+			continue
+		}
+		seen[wallet.Address] = true
+		state, exists := accountStates[wallet.Address]
+		if !exists {
+			continue
+		}
+		accounts = append(accounts, emailAccount{
+			Name:    wallet.Name,
+			Address: wallet.Address,
+			Summary: AccountSummary{
+				AccountValue:    state.LastAccountValue,
+				Withdrawable:    state.LastWithdrawable,
+				TotalMarginUsed: state.LastMarginUsed,
+			},
+			Positions: positionViews(state.LastPositions),
+		})
+	}
+	return accounts
+}
+
+func queueEmailDigest(recipient string, wallet WalletConfig, notification Notification) error {
+	_, err := db.Exec(`
+        INSERT INTO email_digest_queue (recipient, digest, chat_id, address, name, title, text, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+    `, recipient, wallet.Digest, wallet.ChatID, wallet.Address, wallet.Name, notification.Title, notification.Text, time.Now().Unix())
+	return err
+}
+
+// 每分钟检查一次，整点发送小时摘要，每天在配置的小时发送每日摘要
+func runEmailDigests(notifier *EmailNotifier) {
+	var lastHour time.Time
+	for {
+		now := time.Now()
+		hour := now.Truncate(time.Hour)
+		if hour.After(lastHour) {
+			if !lastHour.IsZero() {
+				flushEmailDigests(notifier, DigestHourly, "HyperLiquid每小时摘要")
+				if now.Hour() == notifier.Config.DailyDigestHour {
+					flushEmailDigests(notifier, DigestDaily, "HyperLiquid每日摘要")
+				}
+			}
+			lastHour = hour
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+func flushEmailDigests(notifier *EmailNotifier, digest, title string) {
+	rows, err := db.Query(`
+        SELECT id, recipient, chat_id, address, name, title, text, created_at
+        FROM email_digest_queue WHERE digest = ? ORDER BY id
+    `, digest)
+	if err != nil {
+		log.Printf("读取邮件摘要队列失败: %v", err)
+		return
+	}
+
+	type queued struct {
+		ids    []int64
+		items  []emailItem
+		wallet []WalletConfig
+	}
+	byRecipient := make(map[string]*queued)
+	var recipients []string
+	for rows.Next() {
+		var id, createdAt int64
+		var recipient string
+		var wallet WalletConfig
+		var item emailItem
+		if err := rows.Scan(&id, &recipient, &wallet.ChatID, &wallet.Address, &wallet.Name, &item.Title, &item.Text, &createdAt); err != nil {
+			log.Printf("读取邮件摘要队列失败: %v", err)
+			rows.Close()
+			return
+		}
+		item.Name = wallet.Name
+		item.Time = time.Unix(createdAt, 0).Format("2006-01-02 15:04:05")
+
+		q, exists := byRecipient[recipient]
+		if !exists {
+			q = &queued{}
+			byRecipient[recipient] = q
+			recipients = append(recipients, recipient)
+		}
+		q.ids = append(q.ids, id)
+		q.items = append(q.items, item)
+		q.wallet = append(q.wallet, wallet)
+	}
+	rows.Close()
+
+	for _, recipient := range recipients {
+		q := byRecipient[recipient]
+		data := emailData{
+			Title:       title,
+			GeneratedAt: time.Now().Format("2006-01-02 15:04:05"),
+			Items:       q.items,
+			Accounts:    emailAccounts(q.wallet),
+		}
+		subject := fmt.Sprintf("%s (%d条提醒)", title, len(q.items))
+		if err := notifier.send(recipient, subject, data); err != nil {
+			log.Printf("发送邮件摘要失败 %s: %v", recipient, err)
+			continue
+		}
+		for _, id := range q.ids {
+			if _, err := db.Exec("DELETE FROM email_digest_queue WHERE id = ?", id); err != nil {
+				log.Printf("清理邮件摘要队列失败: %v", err)
+			}
+		}
+	}
+}
+
+func isValidEmail(address string) bool {
+	parsed, err := mail.ParseAddress(address)
+	return err == nil && parsed.Address == address
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+type receivedMail struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string
+}
+
+// fakeSMTPServer 是只实现发信所需命令的本地 SMTP 替身
+type fakeSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	mails    []receivedMail
+}
+
+func startFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &fakeSMTPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var current receivedMail
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			current = receivedMail{From: strings.Trim(line[len("MAIL FROM:"):], "<> ")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			current.To = append(current.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			msg, err := mail.ReadMessage(strings.NewReader(data.String()))
+			if err == nil {
+				current.Subject, _ = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+				body, _ := io.ReadAll(msg.Body)
+				current.Body = string(body)
+			}
+			s.mu.Lock()
+			s.mails = append(s.mails, current)
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) received() []receivedMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]receivedMail(nil), s.mails...)
+}
+
+func (s *fakeSMTPServer) notifier(t *testing.T) *EmailNotifier {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return NewEmailNotifier(SMTPConfig{Host: host, Port: portNumber, From: "Position Monitor <monitor@example.com>"})
+}
+
+const testEmailAddress = "0x1111111111111111111111111111111111111111"
+
+func TestEmailNotifierImmediate(t *testing.T) {
+	setupTestDB(t)
+	server := startFakeSMTPServer(t)
+	notifier := server.notifier(t)
+
+	setAccountState(testEmailAddress, map[string]hyperliquid.Position{
+		"BTC": {Coin: "BTC", Szi: "0.5", PositionValue: "32500", EntryPx: "64000", UnrealizedPnl: "500",
+			Leverage: hyperliquid.Leverage{Type: "cross", Value: 5}},
+	}, AccountSummary{AccountValue: 10000, Withdrawable: 3000, TotalMarginUsed: 6500})
+
+	wallet := WalletConfig{Address: testEmailAddress, Name: "主账户", ChatID: "1", Channel: ChannelEmail, Target: "alice@example.com"}
+	err := notifier.Notify("alice@example.com", Notification{Wallet: wallet, Title: "HyperLiquid持仓变化", Text: "🟢 新开仓: BTC"})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	mails := server.received()
+	if len(mails) != 1 {
+		t.Fatalf("received %d mails, want 1", len(mails))
+	}
+	got := mails[0]
+	if got.From != "monitor@example.com" || len(got.To) != 1 || got.To[0] != "alice@example.com" {
+		t.Errorf("envelope = %q -> %v", got.From, got.To)
+	}
+	if got.Subject != "HyperLiquid持仓变化 - 主账户" {
+		t.Errorf("subject = %q", got.Subject)
+	}
+	for _, want := range []string{"🟢 新开仓: BTC", "$10000.00", "$3000.00", "<td>BTC</td>", "$32500.00", "5x (cross)"} {
+		if !strings.Contains(got.Body, want) {
+			t.Errorf("body missing %q", want)
+		}
+	}
+}
+
+// SMTP 服务器接受连接后不响应时，发送应在超时后失败，而不是一直阻塞
+func TestEmailNotifierTimeout(t *testing.T) {
+	setupTestDB(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+		listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				<-stop
+				conn.Close()
+			}()
+		}
+	}()
+	notifier := (&fakeSMTPServer{listener: listener}).notifier(t)
+	notifier.Timeout = 200 * time.Millisecond
+
+	wallet := WalletConfig{Address: testEmailAddress, Name: "主账户", ChatID: "1", Channel: ChannelEmail, Target: "alice@example.com"}
+	done := make(chan error, 1)
+	go func() {
+		done <- notifier.Notify("alice@example.com", Notification{Wallet: wallet, Title: "HyperLiquid持仓变化", Text: "🟢 新开仓: BTC"})
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected a timeout error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Notify blocked on an unresponsive SMTP server")
+	}
+}
+
+func TestEmailNotifierDigests(t *testing.T) {
+	setupTestDB(t)
+	server := startFakeSMTPServer(t)
+	notifier := server.notifier(t)
+
+	setAccountState(testEmailAddress, map[string]hyperliquid.Position{}, AccountSummary{AccountValue: 2500})
+
+	hourly := WalletConfig{Address: testEmailAddress, Name: "小时账户", ChatID: "1", Digest: DigestHourly}
+	daily := WalletConfig{Address: testEmailAddress, Name: "每日账户", ChatID: "2", Digest: DigestDaily}
+	notifications := []struct {
+		wallet WalletConfig
+		text   string
+	}{
+		{hourly, "第一条提醒"},
+		{hourly, "第二条提醒"},
+		{daily, "每日提醒"},
+	}
+	for _, n := range notifications {
+		if err := notifier.Notify("bob@example.com", Notification{Wallet: n.wallet, Title: "HyperLiquid持仓变化", Text: n.text}); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+	if mails := server.received(); len(mails) != 0 {
+		t.Fatalf("digest notifications were sent immediately: %d", len(mails))
+	}
+
+	flushEmailDigests(notifier, DigestHourly, "HyperLiquid每小时摘要")
+	mails := server.received()
+	if len(mails) != 1 {
+		t.Fatalf("received %d mails after hourly flush, want 1", len(mails))
+	}
+	if mails[0].Subject != "HyperLiquid每小时摘要 (2条提醒)" {
+		t.Errorf("hourly subject = %q", mails[0].Subject)
+	}
+	for _, want := range []string{"第一条提醒", "第二条提醒", "小时账户", "$2500.00", "没有找到开放的持仓"} {
+		if !strings.Contains(mails[0].Body, want) {
+			t.Errorf("hourly body missing %q", want)
+		}
+	}
+	if strings.Contains(mails[0].Body, "每日提醒") {
+		t.Errorf("hourly digest contains daily item")
+	}
+	if strings.Index(mails[0].Body, "第一条提醒") > strings.Index(mails[0].Body, "第二条提醒") {
+		t.Errorf("digest items out of order")
+	}
+
+	// 已发送的条目从队列中移除
+	flushEmailDigests(notifier, DigestHourly, "HyperLiquid每小时摘要")
+	if mails := server.received(); len(mails) != 1 {
+		t.Fatalf("hourly items were sent twice")
+	}
+
+	flushEmailDigests(notifier, DigestDaily, "HyperLiquid每日摘要")
+	mails = server.received()
+	if len(mails) != 2 {
+		t.Fatalf("received %d mails after daily flush, want 2", len(mails))
+	}
+	if mails[1].Subject != "HyperLiquid每日摘要 (1条提醒)" {
+		t.Errorf("daily subject = %q", mails[1].Subject)
+	}
+	if !strings.Contains(mails[1].Body, "每日提醒") || !strings.Contains(mails[1].Body, "每日账户") {
+		t.Errorf("daily body missing item: %s", mails[1].Body)
+	}
+
+	var remaining int
+	if err := db.QueryRow("SELECT COUNT(*) FROM email_digest_queue").Scan(&remaining); err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 0 {
+		t.Errorf("%d items left in digest queue", remaining)
+	}
+}
+
+// 邮件中的账户概览应当是触发通知的那次更新之后的状态
+func TestProcessAccountUpdateEmailShowsCurrentState(t *testing.T) {
+	setupTestDB(t)
+	server := startFakeSMTPServer(t)
+	oldNotifier, registered := notifiers[ChannelEmail]
+	registerNotifier(ChannelEmail, server.notifier(t))
+	t.Cleanup(func() {
+		if registered {
+			registerNotifier(ChannelEmail, oldNotifier)
+		} else {
+			delete(notifiers, ChannelEmail)
+		}
+	})
+
+	wallet := WalletConfig{Address: testEmailAddress, Name: "主账户", ChatID: "1", Channel: ChannelEmail, Target: "alice@example.com"}
+	walletMutex.Lock()
+	wallets[wallet.ChatID+"_"+wallet.Address] = wallet
+	walletMutex.Unlock()
+	setAccountState(testEmailAddress, map[string]hyperliquid.Position{}, AccountSummary{AccountValue: 1000})
+
+	processAccountUpdate(testEmailAddress, []WalletConfig{wallet}, map[string]hyperliquid.Position{
+		"ETH": {Coin: "ETH", Szi: "2", PositionValue: "6200", Leverage: hyperliquid.Leverage{Type: "cross", Value: 3}},
+	}, AccountSummary{AccountValue: 1200})
+
+	mails := server.received()
+	if len(mails) != 1 {
+		t.Fatalf("received %d mails, want 1", len(mails))
+	}
+	if !strings.Contains(mails[0].Body, "<td>ETH</td>") || !strings.Contains(mails[0].Body, "$1200.00") {
+		t.Errorf("email shows stale account state: %s", mails[0].Body)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+// 逐仓保证金变化超过原保证金的该比例才提醒
+const isolatedMarginChangeRatio = 0.01
+
+type PositionEventType string
+
+const (
+	PositionOpen           PositionEventType = "open"
+	PositionIncrease       PositionEventType = "increase"
+	PositionDecrease       PositionEventType = "decrease"
+	PositionClose          PositionEventType = "close"
+	PositionFlip           PositionEventType = "flip"
+	PositionLeverage       PositionEventType = "leverage"
+	PositionMarginMode     PositionEventType = "marginMode"
+	PositionIsolatedMargin PositionEventType = "isolatedMargin"
+	PositionPnl            PositionEventType = "pnl"
+)
+
+// PositionEvent 描述单个币种的一次变化
+//
+// 仓位大小类事件使用 OldSize/NewSize（带方向的 szi），杠杆、逐仓保证金和盈亏事件使用
+// OldValue/NewValue，保证金模式事件使用 OldMode/NewMode。
+type PositionEvent struct {
+	Type          PositionEventType    `json:"type"`
+	Coin          string               `json:"coin"`
+	OldSize       float64              `json:"oldSize"`
+	NewSize       float64              `json:"newSize"`
+	ChangePercent float64              `json:"changePercent"`
+	OldValue      float64              `json:"oldValue,omitempty"`
+	NewValue      float64              `json:"newValue,omitempty"`
+	OldMode       string               `json:"oldMode,omitempty"`
+	NewMode       string               `json:"newMode,omitempty"`
+	Position      hyperliquid.Position `json:"position"` // 当前仓位，平仓时为平仓前的仓位
+}
+
+type AccountEventType string
+
+const (
+	AccountValueChange AccountEventType = "accountValue"
+	WithdrawableChange AccountEventType = "withdrawable"
+	MarginUsedChange   AccountEventType = "marginUsed"
+)
+
+// AccountEvent 描述账户层面金额的变化
+type AccountEvent struct {
+	Type          AccountEventType `json:"type"`
+	OldValue      float64          `json:"oldValue"`
+	NewValue      float64          `json:"newValue"`
+	ChangePercent float64          `json:"changePercent"`
+}
+
+// Changes 是一次检测的结果，交给各渲染器输出
+type Changes struct {
+	Time           time.Time       `json:"time"`
+	PositionEvents []PositionEvent `json:"positionEvents"`
+	AccountEvents  []AccountEvent  `json:"accountEvents"`
+}
+
+func (c Changes) Empty() bool {
+	return len(c.PositionEvents) == 0 && len(c.AccountEvents) == 0
+}
+
+// Priority 取所有事件中最高的优先级：开平仓和反手为高，其他仓位调整为普通，
+// 只有盈亏或账户数值变化时为低
+func (c Changes) Priority() NotificationPriority {
+	priority := PriorityLow
+	for _, event := range c.PositionEvents {
+		eventPriority := PriorityDefault
+		switch event.Type {
+		case PositionOpen, PositionClose, PositionFlip:
+			eventPriority = PriorityHigh
+		case PositionPnl:
+			eventPriority = PriorityLow
+		}
+		priority = max(priority, eventPriority)
+	}
+	return priority
+}
+
+// Detector 按一组阈值比较基线和最新状态，不依赖全局状态
+type Detector struct {
+	Settings          AlertSettings
+	AccountValueAlert *ChangeThreshold
+	WithdrawableAlert *ChangeThreshold
+	MarginUsedAlert   *ChangeThreshold
+}
+
+// 使用订阅设置和全局配置构造检测器
+func newDetector(wallet WalletConfig) Detector {
+	return Detector{
+		Settings:          wallet.Settings,
+		AccountValueAlert: wallet.Settings.accountValueAlert(),
+		WithdrawableAlert: config.WithdrawableAlert,
+		MarginUsedAlert:   config.MarginUsedAlert,
+	}
+}
+
+func (d Detector) Detect(state *AccountState, currentPositions map[string]hyperliquid.Position, summary AccountSummary, now time.Time) Changes {
+	changes := Changes{Time: now}
+
+	sizeChanged := make(map[string]bool)
+	for _, event := range d.detectSizeEvents(state.LastPositions, currentPositions) {
+		changes.PositionEvents = append(changes.PositionEvents, event)
+		sizeChanged[event.Coin] = true
+	}
+
+	coins := make([]string, 0, len(currentPositions))
+	for coin := range currentPositions {
+		coins = append(coins, coin)
+	}
+	sort.Strings(coins)
+
+	for _, coin := range coins {
+		current := currentPositions[coin]
+		last, exists := state.LastPositions[coin]
+		if !exists {
+			continue
+		}
+
+		changes.PositionEvents = append(changes.PositionEvents, detectLeverageEvents(last, current)...)
+		if sizeChanged[coin] {
+			continue
+		}
+
+		pnlChange := d.Settings.pnlChangeUsd()
+		if pnlChange > 0 {
+			currentPnl, _ := strconv.ParseFloat(current.UnrealizedPnl, 64)
+			lastPnl, _ := strconv.ParseFloat(last.UnrealizedPnl, 64)
+			if math.Abs(currentPnl-lastPnl) >= pnlChange {
+				changes.PositionEvents = append(changes.PositionEvents, PositionEvent{
+					Type: PositionPnl, Coin: coin, OldValue: lastPnl, NewValue: currentPnl, Position: current,
+				})
+			}
+		}
+	}
+
+	changes.AccountEvents = d.detectAccountEvents(summary, state)
+	return changes
+}
+
+// 根据前后 szi 判断变化类型，方向相反即为反手
+func classifySizeChange(lastSzi, currentSzi float64) PositionEventType {
+	switch {
+	case lastSzi == 0 && currentSzi == 0:
+		return ""
+	case lastSzi == 0:
+		return PositionOpen
+	case currentSzi == 0:
+		return PositionClose
+	case lastSzi*currentSzi < 0:
+		return PositionFlip
+	case math.Abs(currentSzi) > math.Abs(lastSzi):
+		return PositionIncrease
+	case math.Abs(currentSzi) < math.Abs(lastSzi):
+		return PositionDecrease
+	}
+	return ""
+}
+
+// 按订阅阈值检测开仓、加仓、减仓、平仓和反手
+func (d Detector) detectSizeEvents(lastPositions, currentPositions map[string]hyperliquid.Position) []PositionEvent {
+	var events []PositionEvent
+	minNotional := d.Settings.minNotionalUsd()
+
+	for coin, current := range currentPositions {
+		currentSzi, _ := strconv.ParseFloat(current.Szi, 64)
+		lastSzi := 0.0
+		if last, exists := lastPositions[coin]; exists {
+			lastSzi, _ = strconv.ParseFloat(last.Szi, 64)
+		}
+
+		eventType := classifySizeChange(lastSzi, currentSzi)
+		event := PositionEvent{Type: eventType, Coin: coin, OldSize: lastSzi, NewSize: currentSzi, Position: current}
+		switch eventType {
+		case PositionOpen:
+			if positionNotional(current) < minNotional {
+				continue
+			}
+		case PositionFlip:
+			// 反手总是提醒
+			event.ChangePercent = math.Abs((currentSzi-lastSzi)/lastSzi) * 100
+		case PositionIncrease, PositionDecrease:
+			event.ChangePercent = math.Abs((currentSzi-lastSzi)/lastSzi) * 100
+			changeNotional := math.Abs(currentSzi-lastSzi) * positionPrice(current)
+			if event.ChangePercent < d.Settings.sizeChangePercent() || changeNotional < minNotional {
+				continue
+			}
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+
+	for coin, last := range lastPositions {
+		if _, exists := currentPositions[coin]; exists {
+			continue
+		}
+		if positionNotional(last) < minNotional {
+			continue
+		}
+		lastSzi, _ := strconv.ParseFloat(last.Szi, 64)
+		events = append(events, PositionEvent{Type: PositionClose, Coin: coin, OldSize: lastSzi, ChangePercent: 100, Position: last})
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Coin < events[j].Coin
+	})
+	return events
+}
+
+// 杠杆倍数、保证金模式以及逐仓保证金的调整
+func detectLeverageEvents(last, current hyperliquid.Position) []PositionEvent {
+	var events []PositionEvent
+	coin := current.Coin
+
+	if last.Leverage.Type != "" && last.Leverage.Type != current.Leverage.Type {
+		events = append(events, PositionEvent{
+			Type: PositionMarginMode, Coin: coin, OldMode: last.Leverage.Type, NewMode: current.Leverage.Type, Position: current,
+		})
+	}
+
+	if last.Leverage.Value != 0 && last.Leverage.Value != current.Leverage.Value {
+		events = append(events, PositionEvent{
+			Type: PositionLeverage, Coin: coin,
+			OldValue: float64(last.Leverage.Value), NewValue: float64(current.Leverage.Value), Position: current,
+		})
+	}
+
+	// 逐仓仓位大小不变时 rawUsd 的变化来自手动增减保证金，忽略资金费用带来的小幅波动
+	if last.Szi == current.Szi && last.Leverage.Type == "isolated" && current.Leverage.Type == "isolated" {
+		lastRawUsd, _ := strconv.ParseFloat(last.Leverage.RawUsd, 64)
+		currentRawUsd, _ := strconv.ParseFloat(current.Leverage.RawUsd, 64)
+		lastMargin, _ := strconv.ParseFloat(last.MarginUsed, 64)
+		currentMargin, _ := strconv.ParseFloat(current.MarginUsed, 64)
+		diff := currentRawUsd - lastRawUsd
+		if lastMargin > 0 && math.Abs(diff) >= lastMargin*isolatedMarginChangeRatio {
+			events = append(events, PositionEvent{
+				Type: PositionIsolatedMargin, Coin: coin,
+				OldValue: lastMargin, NewValue: currentMargin, ChangePercent: diff / lastMargin * 100, Position: current,
+			})
+		}
+	}
+
+	return events
+}
+
+// 账户价值、可提取金额和已用保证金的变化，与上次通知时的值比较
+func (d Detector) detectAccountEvents(summary AccountSummary, state *AccountState) []AccountEvent {
+	// 没有基线数据时不比较
+	if state.LastAccountValue == 0 {
+		return nil
+	}
+
+	var events []AccountEvent
+	checks := []struct {
+		eventType AccountEventType
+		threshold *ChangeThreshold
+		last      float64
+		current   float64
+	}{
+		{AccountValueChange, d.AccountValueAlert, state.LastAccountValue, summary.AccountValue},
+		{WithdrawableChange, d.WithdrawableAlert, state.LastWithdrawable, summary.Withdrawable},
+		{MarginUsedChange, d.MarginUsedAlert, state.LastMarginUsed, summary.TotalMarginUsed},
+	}
+	for _, check := range checks {
+		if !check.threshold.exceeded(check.last, check.current) {
+			continue
+		}
+		percent := 0.0
+		if check.last != 0 {
+			percent = (check.current - check.last) / math.Abs(check.last) * 100
+		}
+		events = append(events, AccountEvent{
+			Type: check.eventType, OldValue: check.last, NewValue: check.current, ChangePercent: percent,
+		})
+	}
+	return events
+}
+
+func (t *ChangeThreshold) exceeded(last, current float64) bool {
+	if t == nil {
+		return false
+	}
+	diff := math.Abs(current - last)
+	if t.Usd > 0 && diff >= t.Usd {
+		return true
+	}
+	if t.Percent > 0 && last != 0 && diff/math.Abs(last)*100 >= t.Percent {
+		return true
+	}
+	return false
+}
+
+func positionNotional(position hyperliquid.Position) float64 {
+	posValue, _ := strconv.ParseFloat(position.PositionValue, 64)
+	return math.Abs(posValue)
+}
+
+// 用仓位价值反推标记价格
+func positionPrice(position hyperliquid.Position) float64 {
+	szi, _ := strconv.ParseFloat(position.Szi, 64)
+	if szi == 0 {
+		return 0
+	}
+	return positionNotional(position) / math.Abs(szi)
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// testPosition 构造一个全仓仓位，价格固定为 100
+func testPosition(coin, szi, value string) hyperliquid.Position {
+	return hyperliquid.Position{
+		Coin:          coin,
+		Szi:           szi,
+		PositionValue: value,
+		UnrealizedPnl: "0",
+		MarginUsed:    "10",
+		Leverage:      hyperliquid.Leverage{Type: "cross", Value: 10},
+	}
+}
+
+func withLeverage(position hyperliquid.Position, mode string, value int, rawUsd string) hyperliquid.Position {
+	position.Leverage = hyperliquid.Leverage{Type: mode, Value: value, RawUsd: rawUsd}
+	return position
+}
+
+func withPnl(position hyperliquid.Position, pnl string) hyperliquid.Position {
+	position.UnrealizedPnl = pnl
+	return position
+}
+
+func TestClassifySizeChange(t *testing.T) {
+	tests := []struct {
+		last, current float64
+		want          PositionEventType
+	}{
+		{0, 0, ""},
+		{0, 1, PositionOpen},
+		{0, -1, PositionOpen},
+		{1, 0, PositionClose},
+		{1, 2, PositionIncrease},
+		{-1, -2, PositionIncrease},
+		{2, 1, PositionDecrease},
+		{-2, -1, PositionDecrease},
+		{1, -1, PositionFlip},
+		{-1, 3, PositionFlip},
+		{1, 1, ""},
+	}
+	for _, tt := range tests {
+		if got := classifySizeChange(tt.last, tt.current); got != tt.want {
+			t.Errorf("classifySizeChange(%g, %g) = %q, want %q", tt.last, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestDetectorPositionEvents(t *testing.T) {
+	type wantEvent struct {
+		Type          PositionEventType
+		Coin          string
+		OldSize       float64
+		NewSize       float64
+		ChangePercent float64
+		OldValue      float64
+		NewValue      float64
+		OldMode       string
+		NewMode       string
+	}
+
+	tests := []struct {
+		name     string
+		settings AlertSettings
+		last     map[string]hyperliquid.Position
+		current  map[string]hyperliquid.Position
+		want     []wantEvent
+	}{
+		{
+			name:    "open",
+			last:    map[string]hyperliquid.Position{},
+			current: map[string]hyperliquid.Position{"BTC": testPosition("BTC", "1", "100")},
+			want:    []wantEvent{{Type: PositionOpen, Coin: "BTC", NewSize: 1}},
+		},
+		{
+			name:    "increase",
+			last:    map[string]hyperliquid.Position{"BTC": testPosition("BTC", "1", "100")},
+			current: map[string]hyperliquid.Position{"BTC": testPosition("BTC", "2", "200")},
+			want:    []wantEvent{{Type: PositionIncrease, Coin: "BTC", OldSize: 1, NewSize: 2, ChangePercent: 100}},
+		},
+		{
+			name:    "decrease",
+			last:    map[string]hyperliquid.Position{"BTC": testPosition("BTC", "-4", "400")},
+			current: map[string]hyperliquid.Position{"BTC": testPosition("BTC", "-3", "300")},
+			want:    []wantEvent{{Type: PositionDecrease, Coin: "BTC", OldSize: -4, NewSize: -3, ChangePercent: 25}},
+		},
+		{
+			name:    "close",
+			last:    map[string]hyperliquid.Position{"ETH": testPosition("ETH", "2", "200")},
+			current: map[string]hyperliquid.Position{},
+			want:    []wantEvent{{Type: PositionClose, Coin: "ETH", OldSize: 2, ChangePercent: 100}},
+		},
+		{
+			name:    "flip",
+			last:    map[string]hyperliquid.Position{"BTC": testPosition("BTC", "1", "100")},
+			current: map[string]hyperliquid.Position{"BTC": testPosition("BTC", "-1", "100")},
+			want:    []wantEvent{{Type: PositionFlip, Coin: "BTC", OldSize: 1, NewSize: -1, ChangePercent: 200}},
+		},
+		{
+			name: "flip ignores thresholds",
+			settings: AlertSettings{
+				SizeChangePercent: floatPtr(500),
+				MinNotionalUsd:    floatPtr(1000),
+			},
+			last:    map[string]hyperliquid.Position{"BTC": testPosition("BTC", "1", "100")},
+			current: map[string]hyperliquid.Position{"BTC": testPosition("BTC", "-1", "100")},
+			want:    []wantEvent{{Type: PositionFlip, Coin: "BTC", OldSize: 1, NewSize: -1, ChangePercent: 200}},
+		},
+		{
+			name:    "leverage",
+			last:    map[string]hyperliquid.Position{"BTC": testPosition("BTC", "1", "100")},
+			current: map[string]hyperliquid.Position{"BTC": withLeverage(testPosition("BTC", "1", "100"), "cross", 20, "")},
+			want:    []wantEvent{{Type: PositionLeverage, Coin: "BTC", OldValue: 10, NewValue: 20}},
+		},
+		{
+			name:    "margin mode",
+			last:    map[string]hyperliquid.Position{"BTC": testPosition("BTC", "1", "100")},
+			current: map[string]hyperliquid.Position{"BTC": withLeverage(testPosition("BTC", "1", "100"), "isolated", 10, "-90")},
+			want:    []wantEvent{{Type: PositionMarginMode, Coin: "BTC", OldMode: "cross", NewMode: "isolated"}},
+		},
+		{
+			name: "isolated margin",
+			last: map[string]hyperliquid.Position{"BTC": withLeverage(testPosition("BTC", "1", "100"), "isolated", 10, "-90")},
+			current: map[string]hyperliquid.Position{"BTC": func() hyperliquid.Position {
+				position := withLeverage(testPosition("BTC", "1", "100"), "isolated", 10, "-85")
+				position.MarginUsed = "15"
+				return position
+			}()},
+			want: []wantEvent{{Type: PositionIsolatedMargin, Coin: "BTC", OldValue: 10, NewValue: 15, ChangePercent: 50}},
+		},
+		{
+			name: "isolated margin below ratio",
+			last: map[string]hyperliquid.Position{"BTC": withLeverage(testPosition("BTC", "1", "100"), "isolated", 10, "-90")},
+			current: map[string]hyperliquid.Position{
+				"BTC": withLeverage(testPosition("BTC", "1", "100"), "isolated", 10, "-89.95"),
+			},
+		},
+		{
+			name:     "pnl",
+			settings: AlertSettings{PnlChangeUsd: floatPtr(10)},
+			last:     map[string]hyperliquid.Position{"BTC": testPosition("BTC", "1", "100")},
+			current:  map[string]hyperliquid.Position{"BTC": withPnl(testPosition("BTC", "1", "100"), "-12.5")},
+			want:     []wantEvent{{Type: PositionPnl, Coin: "BTC", OldValue: 0, NewValue: -12.5}},
+		},
+		{
+			name:     "pnl below threshold",
+			settings: AlertSettings{PnlChangeUsd: floatPtr(10)},
+			last:     map[string]hyperliquid.Position{"BTC": testPosition("BTC", "1", "100")},
+			current:  map[string]hyperliquid.Position{"BTC": withPnl(testPosition("BTC", "1", "100"), "9")},
+		},
+		{
+			name:     "pnl suppressed by size change",
+			settings: AlertSettings{PnlChangeUsd: floatPtr(10)},
+			last:     map[string]hyperliquid.Position{"BTC": testPosition("BTC", "1", "100")},
+			current:  map[string]hyperliquid.Position{"BTC": withPnl(testPosition("BTC", "2", "200"), "50")},
+			want:     []wantEvent{{Type: PositionIncrease, Coin: "BTC", OldSize: 1, NewSize: 2, ChangePercent: 100}},
+		},
+		{
+			name:     "minNotional filters open",
+			settings: AlertSettings{MinNotionalUsd: floatPtr(500)},
+			last:     map[string]hyperliquid.Position{},
+			current:  map[string]hyperliquid.Position{"BTC": testPosition("BTC", "1", "100")},
+		},
+		{
+			name:     "minNotional filters close",
+			settings: AlertSettings{MinNotionalUsd: floatPtr(500)},
+			last:     map[string]hyperliquid.Position{"BTC": testPosition("BTC", "1", "100")},
+			current:  map[string]hyperliquid.Position{},
+		},
+		{
+			name:     "minNotional filters small increase",
+			settings: AlertSettings{MinNotionalUsd: floatPtr(150)},
+			last:     map[string]hyperliquid.Position{"BTC": testPosition("BTC", "10", "1000")},
+			current:  map[string]hyperliquid.Position{"BTC": testPosition("BTC", "11", "1100")},
+		},
+		{
+			name:     "minNotional passes large increase",
+			settings: AlertSettings{MinNotionalUsd: floatPtr(150)},
+			last:     map[string]hyperliquid.Position{"BTC": testPosition("BTC", "10", "1000")},
+			current:  map[string]hyperliquid.Position{"BTC": testPosition("BTC", "12", "1200")},
+			want:     []wantEvent{{Type: PositionIncrease, Coin: "BTC", OldSize: 10, NewSize: 12, ChangePercent: 20}},
+		},
+		{
+			name:    "default sizeChangePercent filters small change",
+			last:    map[string]hyperliquid.Position{"BTC": testPosition("BTC", "100", "10000")},
+			current: map[string]hyperliquid.Position{"BTC": testPosition("BTC", "100.5", "10050")},
+		},
+		{
+			name:     "custom sizeChangePercent filters change",
+			settings: AlertSettings{SizeChangePercent: floatPtr(30)},
+			last:     map[string]hyperliquid.Position{"BTC": testPosition("BTC", "4", "400")},
+			current:  map[string]hyperliquid.Position{"BTC": testPosition("BTC", "5", "500")},
+		},
+		{
+			name:     "custom sizeChangePercent passes change",
+			settings: AlertSettings{SizeChangePercent: floatPtr(20)},
+			last:     map[string]hyperliquid.Position{"BTC": testPosition("BTC", "4", "400")},
+			current:  map[string]hyperliquid.Position{"BTC": testPosition("BTC", "5", "500")},
+			want:     []wantEvent{{Type: PositionIncrease, Coin: "BTC", OldSize: 4, NewSize: 5, ChangePercent: 25}},
+		},
+		{
+			name: "events sorted by coin",
+			last: map[string]hyperliquid.Position{"SOL": testPosition("SOL", "1", "100")},
+			current: map[string]hyperliquid.Position{
+				"ETH": testPosition("ETH", "1", "100"),
+				"BTC": testPosition("BTC", "1", "100"),
+			},
+			want: []wantEvent{
+				{Type: PositionOpen, Coin: "BTC", NewSize: 1},
+				{Type: PositionOpen, Coin: "ETH", NewSize: 1},
+				{Type: PositionClose, Coin: "SOL", OldSize: 1, ChangePercent: 100},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := Detector{Settings: tt.settings}
+			state := &AccountState{LastPositions: tt.last}
+			changes := detector.Detect(state, tt.current, AccountSummary{}, time.Now())
+
+			var got []wantEvent
+			for _, event := range changes.PositionEvents {
+				got = append(got, wantEvent{
+					Type: event.Type, Coin: event.Coin, OldSize: event.OldSize, NewSize: event.NewSize,
+					ChangePercent: event.ChangePercent, OldValue: event.OldValue, NewValue: event.NewValue,
+					OldMode: event.OldMode, NewMode: event.NewMode,
+				})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+			if len(changes.AccountEvents) != 0 {
+				t.Errorf("unexpected account events: %+v", changes.AccountEvents)
+			}
+		})
+	}
+}
+
+func TestDetectAccountEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		detector Detector
+		state    AccountState
+		summary  AccountSummary
+		want     []AccountEvent
+	}{
+		{
+			name:     "no baseline",
+			detector: Detector{AccountValueAlert: &ChangeThreshold{Usd: 1}},
+			summary:  AccountSummary{AccountValue: 1000},
+		},
+		{
+			name:     "account value percent",
+			detector: Detector{AccountValueAlert: &ChangeThreshold{Percent: 5}},
+			state:    AccountState{LastAccountValue: 1000},
+			summary:  AccountSummary{AccountValue: 940},
+			want:     []AccountEvent{{Type: AccountValueChange, OldValue: 1000, NewValue: 940, ChangePercent: -6}},
+		},
+		{
+			name: "withdrawable and margin usd",
+			detector: Detector{
+				AccountValueAlert: &ChangeThreshold{Percent: 50},
+				WithdrawableAlert: &ChangeThreshold{Usd: 100},
+				MarginUsedAlert:   &ChangeThreshold{Usd: 100},
+			},
+			state:   AccountState{LastAccountValue: 1000, LastWithdrawable: 500, LastMarginUsed: 200},
+			summary: AccountSummary{AccountValue: 1000, Withdrawable: 350, TotalMarginUsed: 250},
+			want:    []AccountEvent{{Type: WithdrawableChange, OldValue: 500, NewValue: 350, ChangePercent: -30}},
+		},
+		{
+			name: "margin used from zero",
+			detector: Detector{
+				MarginUsedAlert: &ChangeThreshold{Usd: 100},
+			},
+			state:   AccountState{LastAccountValue: 1000},
+			summary: AccountSummary{AccountValue: 1000, TotalMarginUsed: 300},
+			want:    []AccountEvent{{Type: MarginUsedChange, OldValue: 0, NewValue: 300}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			got := tt.detector.detectAccountEvents(tt.summary, &state)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeThresholdExceeded(t *testing.T) {
+	tests := []struct {
+		name          string
+		threshold     *ChangeThreshold
+		last, current float64
+		want          bool
+	}{
+		{"nil threshold", nil, 100, 200, false},
+		{"zero threshold", &ChangeThreshold{}, 100, 200, false},
+		{"usd below", &ChangeThreshold{Usd: 50}, 100, 149, false},
+		{"usd reached", &ChangeThreshold{Usd: 50}, 100, 150, true},
+		{"usd decrease", &ChangeThreshold{Usd: 50}, 100, 40, true},
+		{"percent below", &ChangeThreshold{Percent: 10}, 100, 109, false},
+		{"percent reached", &ChangeThreshold{Percent: 10}, 100, 110, true},
+		{"percent of negative value", &ChangeThreshold{Percent: 10}, -100, -85, true},
+		{"percent from zero", &ChangeThreshold{Percent: 10}, 0, 100, false},
+		{"either branch", &ChangeThreshold{Percent: 50, Usd: 5}, 100, 106, true},
+	}
+	for _, tt := range tests {
+		if got := tt.threshold.exceeded(tt.last, tt.current); got != tt.want {
+			t.Errorf("%s: exceeded(%g, %g) = %v, want %v", tt.name, tt.last, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestChangesPriority(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []PositionEventType
+		want   NotificationPriority
+	}{
+		{"account only", nil, PriorityLow},
+		{"pnl only", []PositionEventType{PositionPnl}, PriorityLow},
+		{"adjustment", []PositionEventType{PositionPnl, PositionIncrease}, PriorityDefault},
+		{"leverage", []PositionEventType{PositionLeverage}, PriorityDefault},
+		{"open", []PositionEventType{PositionDecrease, PositionOpen, PositionPnl}, PriorityHigh},
+		{"flip", []PositionEventType{PositionFlip}, PriorityHigh},
+	}
+	for _, tt := range tests {
+		var changes Changes
+		for _, eventType := range tt.events {
+			changes.PositionEvents = append(changes.PositionEvents, PositionEvent{Type: eventType})
+		}
+		if got := changes.Priority(); got != tt.want {
+			t.Errorf("%s: Priority() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	if !(PriorityLow < PriorityDefault && PriorityDefault < PriorityHigh && PriorityHigh < PriorityUrgent) {
+		t.Errorf("priorities out of order: low=%d default=%d high=%d urgent=%d",
+			PriorityLow, PriorityDefault, PriorityHigh, PriorityUrgent)
+	}
+}
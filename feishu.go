@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ChannelFeishu = "feishu"
+
+// 飞书国内版与国际版（Lark）的自定义机器人地址
+var feishuWebhookPrefixes = []string{
+	"https://open.feishu.cn/open-apis/bot/v2/hook/",
+	"https://open.larksuite.com/open-apis/bot/v2/hook/",
+}
+
+type feishuText struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+type feishuElement struct {
+	Tag  string      `json:"tag"`
+	Text *feishuText `json:"text,omitempty"`
+}
+
+type feishuCardHeader struct {
+	Title    feishuText `json:"title"`
+	Template string     `json:"template"`
+}
+
+type feishuCard struct {
+	Header   feishuCardHeader `json:"header"`
+	Elements []feishuElement  `json:"elements"`
+}
+
+type feishuPayload struct {
+	Timestamp string     `json:"timestamp,omitempty"`
+	Sign      string     `json:"sign,omitempty"`
+	MsgType   string     `json:"msg_type"`
+	Card      feishuCard `json:"card"`
+}
+
+// FeishuNotifier 通过飞书/Lark 自定义机器人发送消息卡片，target 为 Webhook 地址，
+// 订阅的 Secret 不为空时按机器人的签名校验规则签名
+type FeishuNotifier struct {
+	HTTPClient *http.Client
+}
+
+func NewFeishuNotifier() *FeishuNotifier {
+	return &FeishuNotifier{HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (f *FeishuNotifier) Notify(target string, notification Notification) error {
+	payload := feishuPayload{MsgType: "interactive", Card: feishuMessageCard(notification)}
+	if secret := notification.Wallet.Secret; secret != "" {
+		timestamp := time.Now().Unix()
+		payload.Timestamp = strconv.FormatInt(timestamp, 10)
+		payload.Sign = feishuSign(secret, timestamp)
+	}
+	return postBotMessage(f.HTTPClient, target, payload, "飞书")
+}
+
+// 卡片标题为提醒标题和账户，每段事件一个 lark_md 区块，区块之间加分割线
+func feishuMessageCard(notification Notification) feishuCard {
+	wallet := notification.Wallet
+	title := notification.Title
+	if title == "" {
+		title = "HyperLiquid提醒"
+	}
+	sections := markdownSections(notification)
+	card := feishuCard{Header: feishuCardHeader{
+		Title:    feishuText{Tag: "plain_text", Content: fmt.Sprintf("%s - %s", title, wallet.Name)},
+		Template: feishuTemplate(sections[0].Color),
+	}}
+	card.Elements = append(card.Elements, feishuElement{Tag: "div", Text: &feishuText{
+		Tag:     "lark_md",
+		Content: fmt.Sprintf("**账户**: %s (%s)", wallet.Name, shortenAddress(wallet.Address)),
+	}})
+	for _, section := range sections {
+		content := "**" + section.Title + "**"
+		if len(section.Lines) > 0 {
+			content += "\n" + strings.Join(section.Lines, "\n")
+		}
+		card.Elements = append(card.Elements,
+			feishuElement{Tag: "hr"},
+			feishuElement{Tag: "div", Text: &feishuText{Tag: "lark_md", Content: content}},
+		)
+	}
+	return card
+}
+
+func feishuTemplate(color int) string {
+	switch color {
+	case discordColorGreen:
+		return "green"
+	case discordColorRed:
+		return "red"
+	case discordColorOrange:
+		return "orange"
+	}
+	return "blue"
+}
+
+// 飞书签名：以 "时间戳\n密钥" 为 HMAC-SHA256 的密钥对空串签名，再做 Base64
+func feishuSign(secret string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(fmt.Sprintf("%d\n%s", timestamp, secret)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func isValidFeishuWebhook(url string) bool {
+	for _, prefix := range feishuWebhookPrefixes {
+		if strings.HasPrefix(url, prefix) {
+			return true
+		}
+	}
+	return false
+}
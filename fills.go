@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+// userFillsByTime 单次最多返回的成交数
+const fillsPageSize = 2000
+
+var fillMutex sync.Mutex
+
+// FillCursor 是已处理到的成交位置。同一毫秒的成交可能分几次推送或轮询到达，
+// 所以除了时间，还记录该毫秒内已处理过的 Tid
+type FillCursor struct {
+	Time int64
+	Tids []int64
+}
+
+func (c FillCursor) seen(fill hyperliquid.Fill) bool {
+	if fill.Time != c.Time {
+		return fill.Time < c.Time
+	}
+	return slices.Contains(c.Tids, fill.Tid)
+}
+
+// newFillsAfter 返回游标之后尚未处理的成交（按时间排序，同一 Tid 只保留一次）以及处理后的游标
+func newFillsAfter(cursor FillCursor, fills []hyperliquid.Fill) ([]hyperliquid.Fill, FillCursor) {
+	sorted := slices.Clone(fills)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Time != sorted[j].Time {
+			return sorted[i].Time < sorted[j].Time
+		}
+		return sorted[i].Tid < sorted[j].Tid
+	})
+
+	var fresh []hyperliquid.Fill
+	for _, fill := range sorted {
+		if cursor.seen(fill) {
+			continue
+		}
+		fresh = append(fresh, fill)
+		if fill.Time > cursor.Time {
+			cursor = FillCursor{Time: fill.Time}
+		}
+		cursor.Tids = append(slices.Clip(cursor.Tids), fill.Tid)
+	}
+	return fresh, cursor
+}
+
+// fetchFillsAfter 从游标之后分页拉取成交，endTime 为 0 时表示到当前时间，结果按时间排序。
+// 拉满 maxPages 页仍未取完时 complete 为 false，调用方可以从返回的游标继续
+func fetchFillsAfter(address string, cursor FillCursor, endTime int64, maxPages int) (fills []hyperliquid.Fill, next FillCursor, complete bool, err error) {
+	next = cursor
+	for page := 0; page < maxPages; page++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		batch, err := hlClient.UserFillsByTime(ctx, address, next.Time, endTime)
+		cancel()
+		if err != nil {
+			return fills, next, false, fmt.Errorf("获取成交记录失败: %v", err)
+		}
+		var fresh []hyperliquid.Fill
+		fresh, next = newFillsAfter(next, batch)
+		fills = append(fills, fresh...)
+		if len(batch) < fillsPageSize {
+			return fills, next, true, nil
+		}
+		if len(fresh) == 0 {
+			// 整页都是同一毫秒内已处理过的成交，无法再按时间翻页，只能跳过这一毫秒
+			log.Printf("%s 在 %s 的成交超过 %d 条，部分成交可能遗漏", address, time.UnixMilli(next.Time).Format("2006-01-02 15:04:05.000"), fillsPageSize)
+			next = FillCursor{Time: next.Time + 1}
+		}
+	}
+	return fills, next, false, nil
+}
+
+func loadFillCursor(address string) (FillCursor, error) {
+	var cursor FillCursor
+	var tids string
+	err := db.QueryRow("SELECT last_fill_time, last_tids FROM fill_cursors WHERE address = ?", address).Scan(&cursor.Time, &tids)
+	if err == sql.ErrNoRows {
+		return cursor, nil
+	}
+	cursor.Tids = parseTids(tids)
+	return cursor, err
+}
+
+func saveFillCursor(address string, cursor FillCursor) error {
+	_, err := db.Exec(`
+        INSERT OR REPLACE INTO fill_cursors (address, last_fill_time, last_tids)
+        VALUES (?, ?, ?)
+    `, address, cursor.Time, formatTids(cursor.Tids))
+	return err
+}
+
+func deleteFillCursor(address string) error {
+	_, err := db.Exec("DELETE FROM fill_cursors WHERE address = ?", address)
+	return err
+}
+
+func parseTids(text string) []int64 {
+	var tids []int64
+	for _, field := range strings.Split(text, ",") {
+		if tid, err := strconv.ParseInt(field, 10, 64); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids
+}
+
+func formatTids(tids []int64) string {
+	fields := make([]string, len(tids))
+	for i, tid := range tids {
+		fields[i] = strconv.FormatInt(tid, 10)
+	}
+	return strings.Join(fields, ",")
+}
+
+// 拉取上次游标之后的成交并通知订阅者，超过一页的部分留到下次轮询
+func checkFills(address string, subscribers []WalletConfig) {
+	fillMutex.Lock()
+	defer fillMutex.Unlock()
+
+	cursor, err := loadFillCursor(address)
+	if err != nil {
+		log.Printf("读取成交游标失败 %s: %v", address, err)
+		return
+	}
+	// 首次监控该地址时从当前时间开始，不推送历史成交
+	if cursor.Time == 0 {
+		if err := saveFillCursor(address, FillCursor{Time: time.Now().UnixMilli()}); err != nil {
+			log.Printf("保存成交游标失败 %s: %v", address, err)
+		}
+		return
+	}
+
+	fills, _, _, err := fetchFillsAfter(address, cursor, 0, 1)
+	if err != nil {
+		log.Printf("获取 %s 成交失败: %v", address, err)
+		return
+	}
+	notifyNewFills(address, subscribers, cursor, fills)
+}
+
+// 处理 WebSocket 推送的成交，快照只补发游标之后的部分
+func handlePushedFills(address string, subscribers []WalletConfig, fills []hyperliquid.Fill, isSnapshot bool) {
+	fillMutex.Lock()
+	defer fillMutex.Unlock()
+
+	cursor, err := loadFillCursor(address)
+	if err != nil {
+		log.Printf("读取成交游标失败 %s: %v", address, err)
+		return
+	}
+	if isSnapshot && cursor.Time == 0 {
+		if err := saveFillCursor(address, FillCursor{Time: time.Now().UnixMilli()}); err != nil {
+			log.Printf("保存成交游标失败 %s: %v", address, err)
+		}
+		return
+	}
+	notifyNewFills(address, subscribers, cursor, fills)
+}
+
+// 按 (时间, Tid) 去重，轮询和推送先后收到同一毫秒的成交时不会漏发或重复
+func notifyNewFills(address string, subscribers []WalletConfig, cursor FillCursor, fills []hyperliquid.Fill) {
+	newFills, next := newFillsAfter(cursor, fills)
+	if len(newFills) == 0 {
+		return
+	}
+
+	for _, wallet := range subscribers {
+		message := generateFillsMessage(wallet, newFills)
+		if err := notifyWallet(wallet, Notification{Title: "HyperLiquid新成交", Text: message}); err != nil {
+			log.Printf("发送成交通知失败 %s (ChatID: %s): %v", address, wallet.ChatID, err)
+		}
+	}
+
+	if err := saveFillCursor(address, next); err != nil {
+		log.Printf("保存成交游标失败 %s: %v", address, err)
+	}
+}
+
+func generateFillsMessage(wallet WalletConfig, fills []hyperliquid.Fill) string {
+	timeStamp := time.Now().Format("2006-01-02 15:04:05")
+	message := fmt.Sprintf("💱 HyperLiquid新成交 - %s (%s)\n\n", wallet.Name, timeStamp)
+	message += fmt.Sprintf("💼 账户地址: %s\n\n", shortenAddress(wallet.Address))
+
+	for _, fill := range fills {
+		px, _ := strconv.ParseFloat(fill.Px, 64)
+		sz, _ := strconv.ParseFloat(fill.Sz, 64)
+		fee, _ := strconv.ParseFloat(fill.Fee, 64)
+		closedPnl, _ := strconv.ParseFloat(fill.ClosedPnl, 64)
+
+		side := "🟢 买入"
+		if fill.Side == "A" {
+			side = "🔴 卖出"
+		}
+		liquidity := "挂单(Maker)"
+		if fill.Crossed {
+			liquidity = "吃单(Taker)"
+		}
+		feeToken := fill.FeeToken
+		if feeToken == "" {
+			feeToken = "USDC"
+		}
+
+		message += fmt.Sprintf("%s %s (%s)\n", side, fill.Coin, fillDirection(fill.Dir))
+		message += fmt.Sprintf("   🏷️ 成交价格: $%.2f\n", px)
+		message += fmt.Sprintf("   📈 成交数量: %.5f ($%.2f)\n", sz, px*sz)
+		message += fmt.Sprintf("   💸 手续费: %.4f %s (%s)\n", fee, feeToken, liquidity)
+		if closedPnl != 0 {
+			message += fmt.Sprintf("   💰 已实现盈亏: $%.2f\n", closedPnl)
+		}
+		message += fmt.Sprintf("   🕒 成交时间: %s\n\n", time.UnixMilli(fill.Time).Format("2006-01-02 15:04:05"))
+	}
+	return message
+}
+
+func fillDirection(dir string) string {
+	switch dir {
+	case "Open Long":
+		return "开多"
+	case "Open Short":
+		return "开空"
+	case "Close Long":
+		return "平多"
+	case "Close Short":
+		return "平空"
+	case "Long > Short":
+		return "多转空"
+	case "Short > Long":
+		return "空转多"
+	case "Buy":
+		return "现货买入"
+	case "Sell":
+		return "现货卖出"
+	}
+	return dir
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"position-monitor/hyperliquid"
+)
+
+// fakeFillsServer 按 userFillsByTime 的规则返回 startTime（含）到 endTime 之间最早的 2000 条成交
+type fakeFillsServer struct {
+	mu    sync.Mutex
+	fills []hyperliquid.Fill
+	calls int
+}
+
+func startFakeFillsServer(t *testing.T, fills []hyperliquid.Fill) *fakeFillsServer {
+	fake := &fakeFillsServer{fills: fills}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request hyperliquid.UserFillsByTimeRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.Type != "userFillsByTime" {
+			json.NewEncoder(w).Encode([]any{})
+			return
+		}
+
+		fake.mu.Lock()
+		fake.calls++
+		var matched []hyperliquid.Fill
+		for _, fill := range fake.fills {
+			if fill.Time >= request.StartTime && (request.EndTime == nil || fill.Time <= *request.EndTime) {
+				matched = append(matched, fill)
+			}
+		}
+		fake.mu.Unlock()
+
+		sort.SliceStable(matched, func(i, j int) bool { return matched[i].Time < matched[j].Time })
+		if len(matched) > fillsPageSize {
+			matched = matched[:fillsPageSize]
+		}
+		json.NewEncoder(w).Encode(matched)
+	}))
+	oldClient := hlClient
+	hlClient = hyperliquid.NewClient(hyperliquid.WithBaseURL(server.URL))
+	t.Cleanup(func() {
+		server.Close()
+		hlClient = oldClient
+	})
+	return fake
+}
+
+func (f *fakeFillsServer) add(fills ...hyperliquid.Fill) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fills = append(f.fills, fills...)
+}
+
+func testFill(time, tid int64) hyperliquid.Fill {
+	return hyperliquid.Fill{Coin: "BTC", Px: "100", Sz: "1", Side: "B", Time: time, Tid: tid, StartPosition: "0", Dir: "Open Long"}
+}
+
+func fillKeys(fills []hyperliquid.Fill) [][2]int64 {
+	keys := make([][2]int64, len(fills))
+	for i, fill := range fills {
+		keys[i] = [2]int64{fill.Time, fill.Tid}
+	}
+	return keys
+}
+
+func TestNewFillsAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		cursor     FillCursor
+		fills      []hyperliquid.Fill
+		want       [][2]int64
+		wantCursor FillCursor
+	}{
+		{
+			name:       "empty cursor",
+			fills:      []hyperliquid.Fill{testFill(5, 2), testFill(3, 1)},
+			want:       [][2]int64{{3, 1}, {5, 2}},
+			wantCursor: FillCursor{Time: 5, Tids: []int64{2}},
+		},
+		{
+			name:       "same millisecond as cursor",
+			cursor:     FillCursor{Time: 100, Tids: []int64{1}},
+			fills:      []hyperliquid.Fill{testFill(100, 1), testFill(100, 2), testFill(99, 7)},
+			want:       [][2]int64{{100, 2}},
+			wantCursor: FillCursor{Time: 100, Tids: []int64{1, 2}},
+		},
+		{
+			name:       "duplicates in one batch",
+			cursor:     FillCursor{Time: 100, Tids: []int64{1}},
+			fills:      []hyperliquid.Fill{testFill(101, 3), testFill(101, 3), testFill(101, 4)},
+			want:       [][2]int64{{101, 3}, {101, 4}},
+			wantCursor: FillCursor{Time: 101, Tids: []int64{3, 4}},
+		},
+		{
+			name:       "nothing new",
+			cursor:     FillCursor{Time: 100, Tids: []int64{1, 2}},
+			fills:      []hyperliquid.Fill{testFill(100, 2), testFill(50, 9)},
+			wantCursor: FillCursor{Time: 100, Tids: []int64{1, 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, cursor := newFillsAfter(tt.cursor, tt.fills)
+			if keys := fillKeys(got); len(keys) != len(tt.want) || (len(keys) > 0 && !reflect.DeepEqual(keys, tt.want)) {
+				t.Errorf("fills = %v, want %v", keys, tt.want)
+			}
+			if !reflect.DeepEqual(cursor, tt.wantCursor) {
+				t.Errorf("cursor = %+v, want %+v", cursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestFillCursorPersistence(t *testing.T) {
+	setupTestDB(t)
+	cursor := FillCursor{Time: 1700000000000, Tids: []int64{11, 12}}
+	if err := saveFillCursor("0xabc", cursor); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadFillCursor("0xabc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, cursor) {
+		t.Errorf("loaded = %+v, want %+v", loaded, cursor)
+	}
+}
+
+func TestFetchFillsAfterPaging(t *testing.T) {
+	var fills []hyperliquid.Fill
+	tid := int64(0)
+	// 每 3 条成交共用一个毫秒，分页边界会落在同一毫秒中间
+	for i := 0; i < 4500; i++ {
+		tid++
+		fills = append(fills, testFill(1000+int64(i/3), tid))
+	}
+	// 一个毫秒内超过一页的成交
+	for i := 0; i < fillsPageSize+10; i++ {
+		tid++
+		fills = append(fills, testFill(5000, tid))
+	}
+	tid++
+	fills = append(fills, testFill(6000, tid))
+	startFakeFillsServer(t, fills)
+
+	got, cursor, complete, err := fetchFillsAfter("0xabc", FillCursor{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !complete {
+		t.Fatalf("fetch not complete")
+	}
+	seen := make(map[int64]bool)
+	for i, fill := range got {
+		if seen[fill.Tid] {
+			t.Fatalf("fill %d returned twice", fill.Tid)
+		}
+		seen[fill.Tid] = true
+		if i > 0 && fill.Time < got[i-1].Time {
+			t.Fatalf("fills out of order at %d", i)
+		}
+	}
+	for _, fill := range fills[:4500] {
+		if !seen[fill.Tid] {
+			t.Fatalf("fill %d at %d missing", fill.Tid, fill.Time)
+		}
+	}
+	if !seen[tid] || cursor.Time != 6000 {
+		t.Errorf("fills after the oversized millisecond missing, cursor = %+v", cursor)
+	}
+}
+
+func TestFetchFillsAfterMaxPages(t *testing.T) {
+	var fills []hyperliquid.Fill
+	for i := 0; i < 3*fillsPageSize; i++ {
+		fills = append(fills, testFill(int64(i+1), int64(i+1)))
+	}
+	startFakeFillsServer(t, fills)
+
+	got, cursor, complete, err := fetchFillsAfter("0xabc", FillCursor{}, 0, 1)
+	if err != nil || complete || len(got) != fillsPageSize {
+		t.Fatalf("first page: %d fills, complete %v, err %v", len(got), complete, err)
+	}
+	rest, _, complete, err := fetchFillsAfter("0xabc", cursor, 0, 5)
+	if err != nil || !complete || len(rest) != 2*fillsPageSize {
+		t.Fatalf("rest: %d fills, complete %v, err %v", len(rest), complete, err)
+	}
+	if rest[0].Tid != got[len(got)-1].Tid+1 {
+		t.Errorf("pages overlap or skip: %d after %d", rest[0].Tid, got[len(got)-1].Tid)
+	}
+}
+
+// 同一毫秒的成交先由推送送达一笔、再由轮询取到另一笔时，两笔都只通知一次
+func TestFillsSameMillisecondAcrossPushAndPoll(t *testing.T) {
+	setupTestDB(t)
+	notifier := registerTestNotifier(t)
+	address := "0x3333333333333333333333333333333333333333"
+	subscribers := []WalletConfig{{Address: address, Name: "测试", ChatID: "1", Channel: testChannel}}
+	if err := saveFillCursor(address, FillCursor{Time: 1000}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := testFill(2000, 1), testFill(2000, 2)
+	server := startFakeFillsServer(t, []hyperliquid.Fill{first})
+	handlePushedFills(address, subscribers, []hyperliquid.Fill{first}, false)
+	server.add(second)
+	checkFills(address, subscribers)
+	checkFills(address, subscribers)
+	handlePushedFills(address, subscribers, []hyperliquid.Fill{first, second}, false)
+
+	if notifier.count() != 2 {
+		t.Fatalf("got %d notifications, want 2", notifier.count())
+	}
+	for i, notification := range notifier.notifications {
+		if strings.Count(notification.Text, "BTC") != 1 {
+			t.Errorf("notification %d should contain exactly one fill:\n%s", i, notification.Text)
+		}
+	}
+}
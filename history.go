@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+// 旧快照的降采样规则：超过 Age 的数据在每个 Bucket 内只保留最后一条
+var snapshotRetention = []struct {
+	Age    time.Duration
+	Bucket time.Duration
+}{
+	{Age: 24 * time.Hour, Bucket: 15 * time.Minute},
+	{Age: 7 * 24 * time.Hour, Bucket: time.Hour},
+	{Age: 30 * 24 * time.Hour, Bucket: 24 * time.Hour},
+}
+
+var historyPeriods = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+var (
+	lastSnapshotTimes = make(map[string]time.Time)
+	snapshotMutex     sync.Mutex
+)
+
+// snapshotPosition 是快照中的仓位摘要，Value 为带方向的名义价值（空头为负）
+type snapshotPosition struct {
+	Coin          string  `json:"coin"`
+	Size          float64 `json:"size"`
+	Value         float64 `json:"value"`
+	UnrealizedPnl float64 `json:"unrealizedPnl"`
+}
+
+type accountSnapshot struct {
+	Time          time.Time
+	AccountValue  float64
+	Withdrawable  float64
+	MarginUsed    float64
+	UnrealizedPnl float64
+	LongNotional  float64
+	ShortNotional float64
+	Positions     []snapshotPosition
+}
+
+func (s accountSnapshot) grossExposure() float64 {
+	return s.LongNotional + s.ShortNotional
+}
+
+func (s accountSnapshot) netExposure() float64 {
+	return s.LongNotional - s.ShortNotional
+}
+
+// 追加一条快照，同一地址两次快照至少间隔一个轮询周期，WebSocket 推送频繁时不会写入过多数据
+func recordAccountSnapshot(address string, positions map[string]hyperliquid.Position, summary AccountSummary, now time.Time) {
+	address = strings.ToLower(address)
+	snapshotMutex.Lock()
+	if now.Sub(lastSnapshotTimes[address]) < time.Duration(config.PollingInterval)*time.Second {
+		snapshotMutex.Unlock()
+		return
+	}
+	lastSnapshotTimes[address] = now
+	snapshotMutex.Unlock()
+
+	snapshot := newAccountSnapshot(positions, summary, now)
+	positionsJSON, err := json.Marshal(snapshot.Positions)
+	if err != nil {
+		log.Printf("转换快照持仓失败 %s: %v", address, err)
+		return
+	}
+	_, err = db.Exec(`
+        INSERT INTO account_snapshots (address, time, account_value, withdrawable, margin_used, unrealized_pnl, long_notional, short_notional, positions)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, address, now.Unix(), snapshot.AccountValue, snapshot.Withdrawable, snapshot.MarginUsed, snapshot.UnrealizedPnl,
+		snapshot.LongNotional, snapshot.ShortNotional, string(positionsJSON))
+	if err != nil {
+		log.Printf("保存账户快照失败 %s: %v", address, err)
+	}
+}
+
+func newAccountSnapshot(positions map[string]hyperliquid.Position, summary AccountSummary, now time.Time) accountSnapshot {
+	snapshot := accountSnapshot{
+		Time:         now,
+		AccountValue: summary.AccountValue,
+		Withdrawable: summary.Withdrawable,
+		MarginUsed:   summary.TotalMarginUsed,
+	}
+	for _, position := range sortedPositions(positions) {
+		view := newPositionView(position)
+		value := view.Value
+		if view.Direction == "空头" {
+			value = -value
+			snapshot.ShortNotional += view.Value
+		} else {
+			snapshot.LongNotional += view.Value
+		}
+		snapshot.UnrealizedPnl += view.UnrealizedPnl
+		snapshot.Positions = append(snapshot.Positions, snapshotPosition{
+			Coin:          view.Coin,
+			Size:          view.Size,
+			Value:         value,
+			UnrealizedPnl: view.UnrealizedPnl,
+		})
+	}
+	return snapshot
+}
+
+func loadAccountSnapshots(address string, since time.Time) ([]accountSnapshot, error) {
+	rows, err := db.Query(`
+        SELECT time, account_value, withdrawable, margin_used, unrealized_pnl, long_notional, short_notional, positions
+        FROM account_snapshots WHERE address = ? AND time >= ? ORDER BY time
+    `, strings.ToLower(address), since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []accountSnapshot
+	for rows.Next() {
+		var snapshot accountSnapshot
+		var timestamp int64
+		var positionsJSON string
+		if err := rows.Scan(&timestamp, &snapshot.AccountValue, &snapshot.Withdrawable, &snapshot.MarginUsed,
+			&snapshot.UnrealizedPnl, &snapshot.LongNotional, &snapshot.ShortNotional, &positionsJSON); err != nil {
+			return nil, err
+		}
+		snapshot.Time = time.Unix(timestamp, 0)
+		if err := json.Unmarshal([]byte(positionsJSON), &snapshot.Positions); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// 每小时对旧快照降采样一次
+func runSnapshotMaintenance() {
+	for {
+		downsampleSnapshots(time.Now())
+		time.Sleep(time.Hour)
+	}
+}
+
+func downsampleSnapshots(now time.Time) {
+	for i, rule := range snapshotRetention {
+		end := now.Add(-rule.Age).Unix()
+		start := int64(0)
+		if i+1 < len(snapshotRetention) {
+			start = now.Add(-snapshotRetention[i+1].Age).Unix()
+		}
+		bucket := int64(rule.Bucket / time.Second)
+		result, err := db.Exec(`
+            DELETE FROM account_snapshots WHERE time >= ? AND time < ? AND id NOT IN (
+                SELECT MAX(id) FROM account_snapshots WHERE time >= ? AND time < ? GROUP BY address, time / ?
+            )
+        `, start, end, start, end, bucket)
+		if err != nil {
+			log.Printf("账户快照降采样失败: %v", err)
+			return
+		}
+		if deleted, _ := result.RowsAffected(); deleted > 0 {
+			log.Printf("账户快照降采样: 清理 %d 条", deleted)
+		}
+	}
+}
+
+func handleHistoryCommand(chatID, msgText string) {
+	usage := "用法: /history <地址|名称|#标签> [24h|7d|30d]"
+	parts := strings.Fields(msgText)
+	if len(parts) < 2 {
+		sendMessage(chatID, usage)
+		return
+	}
+	period := "24h"
+	selectorParts := parts[1:]
+	if _, exists := historyPeriods[selectorParts[len(selectorParts)-1]]; exists && len(selectorParts) > 1 {
+		period = selectorParts[len(selectorParts)-1]
+		selectorParts = selectorParts[:len(selectorParts)-1]
+	}
+	query := strings.Join(selectorParts, " ")
+
+	targets, ok := statusTargets(chatID, query)
+	if !ok || len(targets) == 0 {
+		sendMessage(chatID, fmt.Sprintf("未找到名称或地址为 %s 的订阅。\n%s", query, usage))
+		return
+	}
+
+	now := time.Now()
+	for _, wallet := range targets {
+		snapshots, err := loadAccountSnapshots(wallet.Address, now.Add(-historyPeriods[period]))
+		if err != nil {
+			log.Printf("读取账户快照失败 %s: %v", wallet.Address, err)
+			sendMessage(chatID, "读取历史数据失败，请稍后重试。")
+			return
+		}
+		sendMessage(chatID, formatHistoryMessage(wallet, period, snapshots))
+	}
+}
+
+func formatHistoryMessage(wallet WalletConfig, period string, snapshots []accountSnapshot) string {
+	message := fmt.Sprintf("📜 HyperLiquid账户历史 - %s (%s)\n\n", wallet.Name, period)
+	message += fmt.Sprintf("💼 账户地址: %s\n", shortenAddress(wallet.Address))
+	if len(snapshots) < 2 {
+		return message + "\n该时间段内的快照不足，请稍后再查看。"
+	}
+
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	message += fmt.Sprintf("🕒 %s → %s (%d个快照)\n\n", first.Time.Format("01-02 15:04"), last.Time.Format("01-02 15:04"), len(snapshots))
+
+	high, low := first.AccountValue, first.AccountValue
+	peak, maxDrawdown := first.AccountValue, 0.0
+	for _, snapshot := range snapshots {
+		high = math.Max(high, snapshot.AccountValue)
+		low = math.Min(low, snapshot.AccountValue)
+		peak = math.Max(peak, snapshot.AccountValue)
+		if peak > 0 {
+			maxDrawdown = math.Max(maxDrawdown, (peak-snapshot.AccountValue)/peak*100)
+		}
+	}
+	change := last.AccountValue - first.AccountValue
+	changePercent := 0.0
+	if first.AccountValue != 0 {
+		changePercent = change / first.AccountValue * 100
+	}
+	message += "💰 账户价值:\n"
+	message += fmt.Sprintf("   $%.2f → $%.2f (%+.2f%%, $%+.2f)\n", first.AccountValue, last.AccountValue, changePercent, change)
+	message += fmt.Sprintf("   最高 $%.2f / 最低 $%.2f\n", high, low)
+	message += fmt.Sprintf("   最大回撤: %.2f%%\n\n", maxDrawdown)
+
+	message += "📊 敞口:\n"
+	message += fmt.Sprintf("   总敞口: $%.2f → $%.2f\n", first.grossExposure(), last.grossExposure())
+	message += fmt.Sprintf("   净敞口: $%+.2f → $%+.2f\n", first.netExposure(), last.netExposure())
+	if last.AccountValue > 0 {
+		message += fmt.Sprintf("   当前杠杆: %.2fx\n", last.grossExposure()/last.AccountValue)
+	}
+	message += fmt.Sprintf("   未实现盈亏: $%.2f → $%.2f\n\n", first.UnrealizedPnl, last.UnrealizedPnl)
+
+	if changes := exposureChanges(first, last); len(changes) > 0 {
+		message += "🪙 持仓变化:\n" + strings.Join(changes, "")
+	}
+	return message
+}
+
+// 按币种比较期初和期末的带方向名义价值
+func exposureChanges(first, last accountSnapshot) []string {
+	values := make(map[string][2]float64)
+	for _, position := range first.Positions {
+		v := values[position.Coin]
+		v[0] = position.Value
+		values[position.Coin] = v
+	}
+	for _, position := range last.Positions {
+		v := values[position.Coin]
+		v[1] = position.Value
+		values[position.Coin] = v
+	}
+	coins := make([]string, 0, len(values))
+	for coin := range values {
+		coins = append(coins, coin)
+	}
+	sort.Strings(coins)
+
+	var lines []string
+	for _, coin := range coins {
+		v := values[coin]
+		switch {
+		case v[0] == 0 && v[1] != 0:
+			lines = append(lines, fmt.Sprintf("   %s: 新开 $%+.2f\n", coin, v[1]))
+		case v[0] != 0 && v[1] == 0:
+			lines = append(lines, fmt.Sprintf("   %s: 已平仓 (期初 $%+.2f)\n", coin, v[0]))
+		default:
+			lines = append(lines, fmt.Sprintf("   %s: $%+.2f → $%+.2f\n", coin, v[0], v[1]))
+		}
+	}
+	return lines
+}
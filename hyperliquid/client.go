@@ -0,0 +1,208 @@
+// Package hyperliquid 提供 HyperLiquid info 接口的客户端
+package hyperliquid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	MainnetURL = "https://api.hyperliquid.xyz/info"
+	TestnetURL = "https://api.hyperliquid-testnet.xyz/info"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// StatusError 表示接口返回了非 200 状态码
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HyperLiquid接口返回状态码 %d: %s", e.StatusCode, e.Body)
+}
+
+// DecodeError 表示接口返回的内容无法解析为预期的 JSON
+type DecodeError struct {
+	Body []byte
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("解析响应时出错: %v", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+type Option func(*Client)
+
+// WithBaseURL 设置 info 接口地址，默认为主网
+func WithBaseURL(url string) Option {
+	return func(c *Client) {
+		if url != "" {
+			c.baseURL = url
+		}
+	}
+}
+
+// WithHTTPClient 设置底层使用的 http.Client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		baseURL:    MainnetURL,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Info 向 info 接口发送任意请求，并将响应解析到 out
+func (c *Client) Info(ctx context.Context, request any, out any) error {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("转换JSON时出错: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("创建请求时出错: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送请求时出错: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应时出错: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return &DecodeError{Body: body, Err: err}
+	}
+	return nil
+}
+
+func (c *Client) ClearinghouseState(ctx context.Context, user string) (*ClearinghouseState, error) {
+	var state ClearinghouseState
+	err := c.Info(ctx, ClearinghouseStateRequest{Type: "clearinghouseState", User: user}, &state)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (c *Client) UserFills(ctx context.Context, user string) ([]Fill, error) {
+	var fills []Fill
+	err := c.Info(ctx, UserFillsRequest{Type: "userFills", User: user}, &fills)
+	if err != nil {
+		return nil, err
+	}
+	return fills, nil
+}
+
+// UserFillsByTime 返回 startTime 之后的成交，endTime 为 0 时表示到当前时间
+func (c *Client) UserFillsByTime(ctx context.Context, user string, startTime, endTime int64) ([]Fill, error) {
+	req := UserFillsByTimeRequest{Type: "userFillsByTime", User: user, StartTime: startTime}
+	if endTime > 0 {
+		req.EndTime = &endTime
+	}
+	var fills []Fill
+	if err := c.Info(ctx, req, &fills); err != nil {
+		return nil, err
+	}
+	return fills, nil
+}
+
+// UserFunding 返回 startTime 之后的资金费结算记录，endTime 为 0 时表示到当前时间
+func (c *Client) UserFunding(ctx context.Context, user string, startTime, endTime int64) ([]FundingEvent, error) {
+	req := UserFundingRequest{Type: "userFunding", User: user, StartTime: startTime}
+	if endTime > 0 {
+		req.EndTime = &endTime
+	}
+	var events []FundingEvent
+	if err := c.Info(ctx, req, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (c *Client) FrontendOpenOrders(ctx context.Context, user string) ([]OpenOrder, error) {
+	var orders []OpenOrder
+	err := c.Info(ctx, FrontendOpenOrdersRequest{Type: "frontendOpenOrders", User: user}, &orders)
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (c *Client) OrderStatus(ctx context.Context, user string, oid int64) (*OrderStatus, error) {
+	var status OrderStatus
+	err := c.Info(ctx, OrderStatusRequest{Type: "orderStatus", User: user, Oid: oid}, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (c *Client) AllMids(ctx context.Context) (map[string]string, error) {
+	var mids map[string]string
+	if err := c.Info(ctx, AllMidsRequest{Type: "allMids"}, &mids); err != nil {
+		return nil, err
+	}
+	return mids, nil
+}
+
+// MetaAndAssetCtxs 的响应是 [meta, assetCtxs] 形式的数组
+func (c *Client) MetaAndAssetCtxs(ctx context.Context) (*Meta, []AssetCtx, error) {
+	var raw []json.RawMessage
+	if err := c.Info(ctx, MetaAndAssetCtxsRequest{Type: "metaAndAssetCtxs"}, &raw); err != nil {
+		return nil, nil, err
+	}
+	if len(raw) != 2 {
+		return nil, nil, &DecodeError{Err: fmt.Errorf("期望2个元素，实际为%d个", len(raw))}
+	}
+
+	var meta Meta
+	if err := json.Unmarshal(raw[0], &meta); err != nil {
+		return nil, nil, &DecodeError{Body: raw[0], Err: err}
+	}
+	var assetCtxs []AssetCtx
+	if err := json.Unmarshal(raw[1], &assetCtxs); err != nil {
+		return nil, nil, &DecodeError{Body: raw[1], Err: err}
+	}
+	return &meta, assetCtxs, nil
+}
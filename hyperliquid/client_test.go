@@ -0,0 +1,186 @@
+package hyperliquid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewClientDefaults(t *testing.T) {
+	c := NewClient(WithBaseURL(""), WithHTTPClient(nil))
+	if c.BaseURL() != MainnetURL {
+		t.Errorf("BaseURL() = %q, want %q", c.BaseURL(), MainnetURL)
+	}
+	if c.httpClient == nil || c.httpClient.Timeout != defaultTimeout {
+		t.Errorf("default http client not set: %+v", c.httpClient)
+	}
+}
+
+func TestWithBaseURLAndHTTPClient(t *testing.T) {
+	var gotRequest map[string]any
+	var gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		gotHeader = r.Header.Get("X-Test")
+		if err := json.NewDecoder(r.Body).Decode(&gotRequest); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Write([]byte(`{"BTC":"65000.5","ETH":"3100"}`))
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{Transport: headerTransport{base: server.Client().Transport}}
+	c := NewClient(WithBaseURL(server.URL), WithHTTPClient(httpClient))
+	if c.BaseURL() != server.URL {
+		t.Fatalf("BaseURL() = %q, want %q", c.BaseURL(), server.URL)
+	}
+
+	mids, err := c.AllMids(context.Background())
+	if err != nil {
+		t.Fatalf("AllMids: %v", err)
+	}
+	if mids["BTC"] != "65000.5" || mids["ETH"] != "3100" {
+		t.Errorf("mids = %v", mids)
+	}
+	if gotRequest["type"] != "allMids" {
+		t.Errorf("request type = %v, want allMids", gotRequest["type"])
+	}
+	if gotHeader != "1" {
+		t.Errorf("custom http client was not used")
+	}
+}
+
+// headerTransport 给请求加上标记头，用来确认使用了注入的 http.Client
+type headerTransport struct {
+	base http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("X-Test", "1")
+	return t.base.RoundTrip(r)
+}
+
+func TestUserFillsByTimeRequest(t *testing.T) {
+	var gotRequest map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotRequest)
+		w.Write([]byte(`[{"coin":"BTC","px":"100","sz":"1","side":"B","time":5,"tid":7}]`))
+	}))
+	defer server.Close()
+
+	c := NewClient(WithBaseURL(server.URL))
+	fills, err := c.UserFillsByTime(context.Background(), "0xabc", 10, 0)
+	if err != nil {
+		t.Fatalf("UserFillsByTime: %v", err)
+	}
+	if len(fills) != 1 || fills[0].Coin != "BTC" || fills[0].Tid != 7 {
+		t.Errorf("fills = %+v", fills)
+	}
+	if gotRequest["type"] != "userFillsByTime" || gotRequest["user"] != "0xabc" || gotRequest["startTime"] != float64(10) {
+		t.Errorf("request = %v", gotRequest)
+	}
+	if _, exists := gotRequest["endTime"]; exists {
+		t.Errorf("endTime should be omitted when 0: %v", gotRequest)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := NewClient(WithBaseURL(server.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.ClearinghouseState(ctx, "0xabc")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request was not cancelled promptly: %v", elapsed)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	c := NewClient(WithBaseURL(server.URL))
+	_, err := c.ClearinghouseState(context.Background(), "0xabc")
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("err = %v (%T), want *StatusError", err, err)
+	}
+	if statusErr.StatusCode != http.StatusTooManyRequests || statusErr.Body != "rate limited\n" {
+		t.Errorf("StatusError = %+v", statusErr)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		call func(*Client) error
+	}{
+		{
+			name: "malformed json",
+			body: `{"marginSummary":`,
+			call: func(c *Client) error {
+				_, err := c.ClearinghouseState(context.Background(), "0xabc")
+				return err
+			},
+		},
+		{
+			name: "wrong shape",
+			body: `{"not":"a list"}`,
+			call: func(c *Client) error {
+				_, err := c.UserFills(context.Background(), "0xabc")
+				return err
+			},
+		},
+		{
+			name: "metaAndAssetCtxs length",
+			body: `[{"universe":[]}]`,
+			call: func(c *Client) error {
+				_, _, err := c.MetaAndAssetCtxs(context.Background())
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			err := tt.call(NewClient(WithBaseURL(server.URL)))
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("err = %v (%T), want *DecodeError", err, err)
+			}
+			if decodeErr.Unwrap() == nil {
+				t.Errorf("DecodeError should wrap the underlying error")
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package hyperliquid
+
+// ClearinghouseStateRequest 对应 info 接口的 clearinghouseState 请求
+type ClearinghouseStateRequest struct {
+	Type string `json:"type"`
+	User string `json:"user"`
+}
+
+type Leverage struct {
+	Type   string `json:"type"`
+	Value  int    `json:"value"`
+	RawUsd string `json:"rawUsd"`
+}
+
+type CumFunding struct {
+	AllTime     string `json:"allTime"`
+	SinceOpen   string `json:"sinceOpen"`
+	SinceChange string `json:"sinceChange"`
+}
+
+type Position struct {
+	Coin           string     `json:"coin"`
+	Szi            string     `json:"szi"`
+	Leverage       Leverage   `json:"leverage"`
+	EntryPx        string     `json:"entryPx"`
+	PositionValue  string     `json:"positionValue"`
+	UnrealizedPnl  string     `json:"unrealizedPnl"`
+	ReturnOnEquity string     `json:"returnOnEquity"`
+	LiquidationPx  string     `json:"liquidationPx"`
+	MarginUsed     string     `json:"marginUsed"`
+	MaxLeverage    int        `json:"maxLeverage"`
+	CumFunding     CumFunding `json:"cumFunding"`
+}
+
+type AssetPosition struct {
+	Type     string   `json:"type"`
+	Position Position `json:"position"`
+}
+
+type MarginSummary struct {
+	AccountValue    string `json:"accountValue"`
+	TotalNtlPos     string `json:"totalNtlPos"`
+	TotalRawUsd     string `json:"totalRawUsd"`
+	TotalMarginUsed string `json:"totalMarginUsed"`
+}
+
+// ClearinghouseState 对应 clearinghouseState 的响应
+type ClearinghouseState struct {
+	MarginSummary              MarginSummary   `json:"marginSummary"`
+	CrossMarginSummary         MarginSummary   `json:"crossMarginSummary"`
+	CrossMaintenanceMarginUsed string          `json:"crossMaintenanceMarginUsed"`
+	Withdrawable               string          `json:"withdrawable"`
+	AssetPositions             []AssetPosition `json:"assetPositions"`
+	Time                       int64           `json:"time"`
+}
+
+// Fill 是一笔成交记录，userFills 接口和 WebSocket 推送共用
+type Fill struct {
+	Coin          string `json:"coin"`
+	Px            string `json:"px"`
+	Sz            string `json:"sz"`
+	Side          string `json:"side"`
+	Time          int64  `json:"time"`
+	StartPosition string `json:"startPosition"`
+	Dir           string `json:"dir"`
+	ClosedPnl     string `json:"closedPnl"`
+	Hash          string `json:"hash"`
+	Oid           int64  `json:"oid"`
+	Crossed       bool   `json:"crossed"`
+	Fee           string `json:"fee"`
+	Tid           int64  `json:"tid"`
+	FeeToken      string `json:"feeToken"`
+}
+
+// UserFillsRequest 对应 userFills 请求，返回最近的成交
+type UserFillsRequest struct {
+	Type            string `json:"type"`
+	User            string `json:"user"`
+	AggregateByTime bool   `json:"aggregateByTime,omitempty"`
+}
+
+// UserFillsByTimeRequest 对应 userFillsByTime 请求，时间为毫秒时间戳
+type UserFillsByTimeRequest struct {
+	Type            string `json:"type"`
+	User            string `json:"user"`
+	StartTime       int64  `json:"startTime"`
+	EndTime         *int64 `json:"endTime,omitempty"`
+	AggregateByTime bool   `json:"aggregateByTime,omitempty"`
+}
+
+// UserFundingRequest 对应 userFunding 请求，时间为毫秒时间戳
+type UserFundingRequest struct {
+	Type      string `json:"type"`
+	User      string `json:"user"`
+	StartTime int64  `json:"startTime"`
+	EndTime   *int64 `json:"endTime,omitempty"`
+}
+
+// FundingDelta 是一次资金费结算，Usdc 为负表示支付
+type FundingDelta struct {
+	Type        string `json:"type"`
+	Coin        string `json:"coin"`
+	Usdc        string `json:"usdc"`
+	Szi         string `json:"szi"`
+	FundingRate string `json:"fundingRate"`
+}
+
+type FundingEvent struct {
+	Time  int64        `json:"time"`
+	Hash  string       `json:"hash"`
+	Delta FundingDelta `json:"delta"`
+}
+
+// FrontendOpenOrdersRequest 对应 frontendOpenOrders 请求
+type FrontendOpenOrdersRequest struct {
+	Type string `json:"type"`
+	User string `json:"user"`
+}
+
+// OpenOrder 是 frontendOpenOrders 返回的挂单，包含触发单信息
+type OpenOrder struct {
+	Coin             string `json:"coin"`
+	Side             string `json:"side"`
+	LimitPx          string `json:"limitPx"`
+	Sz               string `json:"sz"`
+	Oid              int64  `json:"oid"`
+	Timestamp        int64  `json:"timestamp"`
+	TriggerCondition string `json:"triggerCondition"`
+	IsTrigger        bool   `json:"isTrigger"`
+	TriggerPx        string `json:"triggerPx"`
+	IsPositionTpsl   bool   `json:"isPositionTpsl"`
+	ReduceOnly       bool   `json:"reduceOnly"`
+	OrderType        string `json:"orderType"`
+	OrigSz           string `json:"origSz"`
+	Tif              string `json:"tif"`
+	Cloid            string `json:"cloid"`
+}
+
+// OrderStatusRequest 对应 orderStatus 请求
+type OrderStatusRequest struct {
+	Type string `json:"type"`
+	User string `json:"user"`
+	Oid  int64  `json:"oid"`
+}
+
+// OrderStatus 对应 orderStatus 的响应，Status 为 unknownOid 时 Order 为空
+type OrderStatus struct {
+	Status string `json:"status"`
+	Order  *struct {
+		Order           OpenOrder `json:"order"`
+		Status          string    `json:"status"`
+		StatusTimestamp int64     `json:"statusTimestamp"`
+	} `json:"order"`
+}
+
+// AllMidsRequest 对应 allMids 请求，返回币种到中间价的映射
+type AllMidsRequest struct {
+	Type string `json:"type"`
+}
+
+// MetaAndAssetCtxsRequest 对应 metaAndAssetCtxs 请求
+type MetaAndAssetCtxsRequest struct {
+	Type string `json:"type"`
+}
+
+type AssetInfo struct {
+	Name         string `json:"name"`
+	SzDecimals   int    `json:"szDecimals"`
+	MaxLeverage  int    `json:"maxLeverage"`
+	OnlyIsolated bool   `json:"onlyIsolated"`
+	IsDelisted   bool   `json:"isDelisted"`
+}
+
+type Meta struct {
+	Universe []AssetInfo `json:"universe"`
+}
+
+// AssetCtx 与 Meta.Universe 按下标一一对应
+type AssetCtx struct {
+	Funding      string   `json:"funding"`
+	OpenInterest string   `json:"openInterest"`
+	PrevDayPx    string   `json:"prevDayPx"`
+	DayNtlVlm    string   `json:"dayNtlVlm"`
+	Premium      string   `json:"premium"`
+	OraclePx     string   `json:"oraclePx"`
+	MarkPx       string   `json:"markPx"`
+	MidPx        string   `json:"midPx"`
+	ImpactPxs    []string `json:"impactPxs"`
+}
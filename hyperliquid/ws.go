@@ -0,0 +1,243 @@
+package hyperliquid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	MainnetWSURL = "wss://api.hyperliquid.xyz/ws"
+	TestnetWSURL = "wss://api.hyperliquid-testnet.xyz/ws"
+
+	// 服务端在 60 秒无消息后会断开连接
+	pingInterval      = 50 * time.Second
+	readTimeout       = 90 * time.Second
+	writeTimeout      = 10 * time.Second
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// Subscription 对应 WebSocket 订阅消息中的 subscription 字段
+type Subscription struct {
+	Type string `json:"type"`
+	User string `json:"user,omitempty"`
+	Coin string `json:"coin,omitempty"`
+}
+
+// WSMessage 是服务端推送的原始消息
+type WSMessage struct {
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// WebData2 是 webData2 频道推送的数据，只解析监控需要的字段
+type WebData2 struct {
+	ClearinghouseState ClearinghouseState `json:"clearinghouseState"`
+	OpenOrders         []OpenOrder        `json:"openOrders"`
+	User               string             `json:"user"`
+	ServerTime         int64              `json:"serverTime"`
+}
+
+type wsRequest struct {
+	Method       string        `json:"method"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+}
+
+// WSClient 维护一条到 HyperLiquid 的 WebSocket 连接，断线后自动重连并恢复订阅
+type WSClient struct {
+	url    string
+	dialer *websocket.Dialer
+
+	mu            sync.Mutex
+	conn          *websocket.Conn
+	subscriptions map[Subscription]bool
+	connected     bool
+	writeMu       sync.Mutex
+}
+
+func NewWSClient(url string) *WSClient {
+	if url == "" {
+		url = MainnetWSURL
+	}
+	return &WSClient{
+		url:           url,
+		dialer:        websocket.DefaultDialer,
+		subscriptions: make(map[Subscription]bool),
+	}
+}
+
+// Connected 返回当前连接是否可用
+func (c *WSClient) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+// Subscriptions 返回当前登记的全部订阅
+func (c *WSClient) Subscriptions() []Subscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	subs := make([]Subscription, 0, len(c.subscriptions))
+	for sub := range c.subscriptions {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// Subscribe 登记订阅，如果已连接则立即发送
+func (c *WSClient) Subscribe(sub Subscription) error {
+	c.mu.Lock()
+	if c.subscriptions[sub] {
+		c.mu.Unlock()
+		return nil
+	}
+	c.subscriptions[sub] = true
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return c.write(conn, wsRequest{Method: "subscribe", Subscription: &sub})
+}
+
+// Unsubscribe 取消订阅，如果已连接则立即发送
+func (c *WSClient) Unsubscribe(sub Subscription) error {
+	c.mu.Lock()
+	if !c.subscriptions[sub] {
+		c.mu.Unlock()
+		return nil
+	}
+	delete(c.subscriptions, sub)
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return c.write(conn, wsRequest{Method: "unsubscribe", Subscription: &sub})
+}
+
+// Run 持续保持连接并把收到的消息交给 handler，直到 ctx 被取消
+func (c *WSClient) Run(ctx context.Context, handler func(WSMessage)) {
+	delay := minReconnectDelay
+	for {
+		start := time.Now()
+		err := c.runOnce(ctx, handler)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("WebSocket连接断开: %v", err)
+
+		// 连接维持了较长时间说明不是持续性故障，重置退避
+		if time.Since(start) > maxReconnectDelay {
+			delay = minReconnectDelay
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+func (c *WSClient) runOnce(ctx context.Context, handler func(WSMessage)) error {
+	conn, _, err := c.dialer.DialContext(ctx, c.url, nil)
+	if err != nil {
+		return fmt.Errorf("连接失败: %v", err)
+	}
+	defer conn.Close()
+
+	c.mu.Lock()
+	c.conn = conn
+	subs := make([]Subscription, 0, len(c.subscriptions))
+	for sub := range c.subscriptions {
+		subs = append(subs, sub)
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.connected = false
+		c.mu.Unlock()
+	}()
+
+	for i := range subs {
+		if err := c.write(conn, wsRequest{Method: "subscribe", Subscription: &subs[i]}); err != nil {
+			return fmt.Errorf("恢复订阅失败: %v", err)
+		}
+	}
+
+	c.mu.Lock()
+	c.connected = true
+	c.mu.Unlock()
+	log.Printf("WebSocket已连接: %s (%d 个订阅)", c.url, len(subs))
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-ticker.C:
+				if err := c.write(conn, wsRequest{Method: "ping"}); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var msg WSMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Printf("解析WebSocket消息失败: %v", err)
+			continue
+		}
+		switch msg.Channel {
+		case "pong", "subscriptionResponse":
+			continue
+		case "error":
+			log.Printf("WebSocket返回错误: %s", string(msg.Data))
+			continue
+		}
+		handler(msg)
+	}
+}
+
+func (c *WSClient) write(conn *websocket.Conn, req wsRequest) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return conn.WriteJSON(req)
+}
+
+// WSUserFills 是 userFills 频道推送的数据，首条消息为快照
+type WSUserFills struct {
+	IsSnapshot bool   `json:"isSnapshot"`
+	User       string `json:"user"`
+	Fills      []Fill `json:"fills"`
+}
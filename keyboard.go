@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// 回调数据格式为 "<动作>:<地址>"，地址 42 个字符，不超过 Telegram 64 字节的限制
+const (
+	callbackList        = "list"
+	callbackWallet      = "wallet"
+	callbackStatus      = "status"
+	callbackRename      = "rename"
+	callbackMute        = "mute"
+	callbackSettings    = "settings"
+	callbackUnsubscribe = "unsub"
+	callbackConfirm     = "unsubok"
+)
+
+// pendingRename 记录等待用户回复新名称的重命名请求，只接受对提示消息的回复
+type pendingRename struct {
+	Address  string
+	PromptID int
+}
+
+var pendingRenames = make(map[string]pendingRename)
+
+// 订阅列表：每个订阅一个按钮，点击后进入该订阅的操作菜单
+func listSubscriptions(chatID, tag string) {
+	text, markup := subscriptionListView(chatID, tag)
+	msg := tgbotapi.NewMessageToChannel(chatID, text)
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	sendChattable(chatID, msg, nil)
+}
+
+// tag 不为空时只列出带该标签的订阅
+func subscriptionListView(chatID, tag string) (string, *tgbotapi.InlineKeyboardMarkup) {
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	message := "📋 您的订阅列表:\n\n"
+	if tag != "" {
+		message = fmt.Sprintf("📋 您的订阅列表 (%s):\n\n", tag)
+	}
+	for _, wallet := range matchWallets(chatID, tag) {
+		message += fmt.Sprintf("%d. %s - %s", len(rows)+1, wallet.Address, wallet.Name)
+		if len(wallet.Tags) > 0 {
+			message += " " + formatTags(wallet.Tags)
+		}
+		if channel, _ := wallet.destination(); channel != ChannelTelegram {
+			message += fmt.Sprintf(" [%s]", channelName(channel))
+		}
+		label := wallet.Name + " (" + shortenAddress(wallet.Address) + ")"
+		if wallet.isMuted(time.Now()) {
+			message += " 🔕"
+			label = "🔕 " + label
+		}
+		message += "\n"
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, callbackWallet+":"+wallet.Address),
+		))
+	}
+	if len(rows) == 0 {
+		if tag != "" {
+			return fmt.Sprintf("没有带标签 %s 的订阅。", tag), nil
+		}
+		return "您尚未订阅任何地址。", nil
+	}
+	message += "\n点击下方按钮管理订阅。"
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return message, &markup
+}
+
+// 按地址排序的某个聊天的订阅，调用方需持有 walletMutex
+func chatWallets(chatID string) []WalletConfig {
+	var result []WalletConfig
+	for key, wallet := range wallets {
+		if strings.HasPrefix(key, chatID+"_") {
+			result = append(result, wallet)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Address < result[j].Address
+	})
+	return result
+}
+
+func walletMenuView(wallet WalletConfig) (string, tgbotapi.InlineKeyboardMarkup) {
+	message := fmt.Sprintf("💼 %s\n%s", wallet.Name, wallet.Address)
+	if len(wallet.Tags) > 0 {
+		message += "\n标签: " + formatTags(wallet.Tags)
+	}
+	if channel, _ := wallet.destination(); channel != ChannelTelegram {
+		message += fmt.Sprintf("\n提醒渠道: %s", channelName(channel))
+	}
+	muteLabel := "🔕 静音"
+	if now := time.Now(); wallet.Muted {
+		message += "\n🔕 已静音"
+		muteLabel = "🔔 取消静音"
+	} else if wallet.isMuted(now) {
+		message += "\n🔕 已静音至 " + wallet.MutedUntil.Format("2006-01-02 15:04")
+		muteLabel = "🔔 取消静音"
+	}
+	address := wallet.Address
+	return message, tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📊 状态", callbackStatus+":"+address),
+			tgbotapi.NewInlineKeyboardButtonData("✏️ 重命名", callbackRename+":"+address),
+			tgbotapi.NewInlineKeyboardButtonData(muteLabel, callbackMute+":"+address),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⚙️ 设置", callbackSettings+":"+address),
+			tgbotapi.NewInlineKeyboardButtonData("❌ 取消订阅", callbackUnsubscribe+":"+address),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("« 返回列表", callbackList),
+		),
+	)
+}
+
+func handleCallbackQuery(query *tgbotapi.CallbackQuery) {
+	if query.Message == nil {
+		return
+	}
+	chatID := strconv.FormatInt(query.Message.Chat.ID, 10)
+	messageID := query.Message.MessageID
+	action, address, _ := strings.Cut(query.Data, ":")
+	answer := ""
+
+	defer func() {
+		if _, err := bot.Request(tgbotapi.NewCallback(query.ID, answer)); err != nil {
+			log.Printf("应答回调失败: %v", err)
+		}
+	}()
+
+	if action == callbackList {
+		text, markup := subscriptionListView(chatID, "")
+		editMessage(query.Message.Chat.ID, messageID, text, markup)
+		return
+	}
+
+	walletMutex.Lock()
+	wallet, exists := wallets[chatID+"_"+address]
+	walletMutex.Unlock()
+	if !exists {
+		answer = "该地址已不在订阅列表中"
+		text, markup := subscriptionListView(chatID, "")
+		editMessage(query.Message.Chat.ID, messageID, text, markup)
+		return
+	}
+
+	switch action {
+	case callbackWallet:
+		text, markup := walletMenuView(wallet)
+		editMessage(query.Message.Chat.ID, messageID, text, &markup)
+
+	case callbackStatus:
+		answer = "正在获取最新状态…"
+		handleStatusCommand(chatID, "/status "+address)
+
+	case callbackSettings:
+		showSettings(chatID, address)
+
+	case callbackRename:
+		prompt := tgbotapi.NewMessage(query.Message.Chat.ID, fmt.Sprintf("请回复此消息输入 %s 的新名称", shortenAddress(address)))
+		prompt.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+		// 提示发出后才知道消息 ID
+		sendChattable(chatID, prompt, func(sent tgbotapi.Message) {
+			walletMutex.Lock()
+			pendingRenames[chatID] = pendingRename{Address: address, PromptID: sent.MessageID}
+			walletMutex.Unlock()
+		})
+
+	case callbackMute:
+		muted := !wallet.isMuted(time.Now())
+		updated, ok := setWalletMute(chatID, address, muted, time.Time{})
+		if !ok {
+			answer = "保存失败，请稍后重试"
+			return
+		}
+		answer = "已取消静音"
+		if muted {
+			answer = "已静音，不再发送该地址的提醒"
+		}
+		text, markup := walletMenuView(updated)
+		editMessage(query.Message.Chat.ID, messageID, text, &markup)
+
+	case callbackUnsubscribe:
+		markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("确认取消订阅", callbackConfirm+":"+address),
+			tgbotapi.NewInlineKeyboardButtonData("« 返回", callbackWallet+":"+address),
+		))
+		text := fmt.Sprintf("确定取消订阅 %s (%s) 吗？", wallet.Name, shortenAddress(address))
+		editMessage(query.Message.Chat.ID, messageID, text, &markup)
+
+	case callbackConfirm:
+		unsubscribeWallet(chatID, address)
+		text, markup := subscriptionListView(chatID, "")
+		editMessage(query.Message.Chat.ID, messageID, text, markup)
+	}
+}
+
+func editMessage(chatID int64, messageID int, text string, markup *tgbotapi.InlineKeyboardMarkup) {
+	var edit tgbotapi.EditMessageTextConfig
+	if markup != nil {
+		edit = tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, *markup)
+	} else {
+		edit = tgbotapi.NewEditMessageText(chatID, messageID, text)
+	}
+	sendChattable(strconv.FormatInt(chatID, 10), edit, nil)
+}
+
+// 处理对重命名提示的回复，返回是否已处理
+func handlePendingRename(chatID string, message *tgbotapi.Message) bool {
+	if message.ReplyToMessage == nil {
+		return false
+	}
+	walletMutex.Lock()
+	pending, exists := pendingRenames[chatID]
+	if exists && pending.PromptID == message.ReplyToMessage.MessageID {
+		delete(pendingRenames, chatID)
+	}
+	walletMutex.Unlock()
+	if !exists || pending.PromptID != message.ReplyToMessage.MessageID {
+		return false
+	}
+	renameWallet(chatID, pending.Address, strings.TrimSpace(message.Text))
+	return true
+}
+
+func renameWallet(chatID, address, name string) {
+	if name == "" {
+		sendMessage(chatID, "名称不能为空。")
+		return
+	}
+
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+
+	key := chatID + "_" + address
+	wallet, exists := wallets[key]
+	if !exists {
+		sendMessage(chatID, fmt.Sprintf("地址 %s 未被订阅", shortenAddress(address)))
+		return
+	}
+	if _, err := db.Exec("UPDATE subscriptions SET name = ? WHERE chat_id = ? AND address = ?", name, chatID, address); err != nil {
+		log.Printf("保存名称失败: %v", err)
+		sendMessage(chatID, "保存名称失败，请稍后重试。")
+		return
+	}
+	wallet.Name = name
+	wallets[key] = wallet
+	sendMessage(chatID, fmt.Sprintf("地址 %s 已重命名为 %s", shortenAddress(address), name))
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+var leaderboardSorts = map[string]string{
+	"pnl":      "盈亏",
+	"roi":      "收益率",
+	"winrate":  "胜率",
+	"drawdown": "回撤",
+}
+
+// leaderboardEntry 是排行榜中的一个地址，数据来自已保存的账户快照和往返交易
+type leaderboardEntry struct {
+	Wallet      WalletConfig
+	Subscribers int
+	HasHistory  bool
+	Pnl         float64
+	Roi         float64
+	Drawdown    float64 // 权益曲线的最大回撤百分比
+	Trades      int
+	Wins        int
+}
+
+func (e leaderboardEntry) winRate() float64 {
+	if e.Trades == 0 {
+		return 0
+	}
+	return float64(e.Wins) / float64(e.Trades) * 100
+}
+
+func handleLeaderboardCommand(chatID, msgText string) {
+	usage := "用法: /leaderboard [24h|7d|30d] [pnl|roi|winrate|drawdown]"
+	period, sortBy := "7d", "pnl"
+	for _, arg := range strings.Fields(msgText)[1:] {
+		arg = strings.ToLower(arg)
+		if _, exists := historyPeriods[arg]; exists {
+			period = arg
+		} else if _, exists := leaderboardSorts[arg]; exists {
+			sortBy = arg
+		} else {
+			sendMessage(chatID, usage)
+			return
+		}
+	}
+
+	candidates := leaderboardCandidates(chatID)
+	if len(candidates) == 0 {
+		sendMessage(chatID, "您还没有订阅任何地址。")
+		return
+	}
+
+	since := time.Now().Add(-historyPeriods[period])
+	entries := make([]leaderboardEntry, 0, len(candidates))
+	for _, entry := range candidates {
+		if err := fillLeaderboardEntry(&entry, since); err != nil {
+			log.Printf("计算排行榜数据失败 %s: %v", entry.Wallet.Address, err)
+			sendMessage(chatID, "读取历史数据失败，请稍后重试。")
+			return
+		}
+		entries = append(entries, entry)
+	}
+	sortLeaderboard(entries, sortBy)
+	sendMessage(chatID, formatLeaderboard(entries, period, sortBy, chatID == config.SuperAdminID))
+}
+
+// 超级管理员查看所有被监控的地址，其他用户只看自己订阅的地址
+func leaderboardCandidates(chatID string) []leaderboardEntry {
+	var candidates []leaderboardEntry
+	if chatID == config.SuperAdminID {
+		// 同一地址可能以不同大小写被订阅
+		grouped := make(map[string][]WalletConfig)
+		for address, subscribers := range subscribersByAddress() {
+			key := strings.ToLower(address)
+			grouped[key] = append(grouped[key], subscribers...)
+		}
+		for _, subscribers := range grouped {
+			wallet := subscribers[0]
+			for _, subscriber := range subscribers {
+				if subscriber.ChatID == chatID {
+					wallet = subscriber
+				}
+			}
+			candidates = append(candidates, leaderboardEntry{Wallet: wallet, Subscribers: len(subscribers)})
+		}
+	} else {
+		walletMutex.Lock()
+		for _, wallet := range chatWallets(chatID) {
+			candidates = append(candidates, leaderboardEntry{Wallet: wallet, Subscribers: 1})
+		}
+		walletMutex.Unlock()
+	}
+	return candidates
+}
+
+// 盈亏只统计期间内（第一个快照之后）开仓的交易：已平仓的计净盈亏，未平仓的再加上最后一个快照中的
+// 未实现盈亏。期初已持有的仓位不计入，其在期初前已实现或浮动的盈亏无法从交易记录中拆分，
+// 计入会重复或偏移；出入金也不影响盈亏。胜率按期间内平仓的交易计算，回撤仍按账户权益计算
+func fillLeaderboardEntry(entry *leaderboardEntry, since time.Time) error {
+	trades, err := loadTrades(strings.ToLower(entry.Wallet.Address), false, since)
+	if err != nil {
+		return err
+	}
+	stats := computeTradeStats(trades)
+	entry.Trades = stats.Trades
+	entry.Wins = stats.Wins
+
+	snapshots, err := loadAccountSnapshots(entry.Wallet.Address, since)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) >= 2 {
+		first, last := snapshots[0], snapshots[len(snapshots)-1]
+		entry.HasHistory = true
+		unrealized := make(map[string]float64)
+		for _, position := range last.Positions {
+			unrealized[position.Coin] = position.UnrealizedPnl
+		}
+		for _, trade := range trades {
+			if trade.OpenTime.Before(first.Time) {
+				continue
+			}
+			entry.Pnl += trade.netPnl()
+			if !trade.closed() {
+				entry.Pnl += unrealized[trade.Coin]
+			}
+		}
+		if first.AccountValue > 0 {
+			entry.Roi = entry.Pnl / first.AccountValue * 100
+		}
+		peak := 0.0
+		for _, snapshot := range snapshots {
+			peak = math.Max(peak, snapshot.AccountValue)
+			if peak > 0 {
+				entry.Drawdown = math.Max(entry.Drawdown, (peak-snapshot.AccountValue)/peak*100)
+			}
+		}
+	}
+	return nil
+}
+
+// 没有快照的地址排在最后；按胜率排序时没有已完成交易的地址也排在最后
+func sortLeaderboard(entries []leaderboardEntry, sortBy string) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if sortBy == "winrate" {
+			if (a.Trades > 0) != (b.Trades > 0) {
+				return a.Trades > 0
+			}
+			if a.winRate() != b.winRate() {
+				return a.winRate() > b.winRate()
+			}
+			return a.Trades > b.Trades
+		}
+		if a.HasHistory != b.HasHistory {
+			return a.HasHistory
+		}
+		switch sortBy {
+		case "roi":
+			return a.Roi > b.Roi
+		case "drawdown":
+			return a.Drawdown < b.Drawdown
+		}
+		return a.Pnl > b.Pnl
+	})
+}
+
+func formatLeaderboard(entries []leaderboardEntry, period, sortBy string, allWallets bool) string {
+	scope := "我的订阅"
+	if allWallets {
+		scope = "全部地址"
+	}
+	message := fmt.Sprintf("🏅 HyperLiquid排行榜 - %s (%s，按%s排序)\n\n", scope, period, leaderboardSorts[sortBy])
+
+	medals := []string{"🥇", "🥈", "🥉"}
+	for i, entry := range entries {
+		rank := fmt.Sprintf("%d.", i+1)
+		if i < len(medals) {
+			rank = medals[i]
+		}
+		message += fmt.Sprintf("%s %s (%s)", rank, entry.Wallet.Name, shortenAddress(entry.Wallet.Address))
+		if allWallets && entry.Subscribers > 1 {
+			message += fmt.Sprintf(" 👥%d", entry.Subscribers)
+		}
+		message += "\n"
+
+		if entry.HasHistory {
+			message += fmt.Sprintf("   💰 $%+.2f (%+.2f%%) | 📉 回撤 %.2f%%\n", entry.Pnl, entry.Roi, entry.Drawdown)
+		} else {
+			message += "   💰 暂无历史数据\n"
+		}
+		if entry.Trades > 0 {
+			message += fmt.Sprintf("   🎯 胜率 %.1f%% (%d胜 %d负)\n", entry.winRate(), entry.Wins, entry.Trades-entry.Wins)
+		} else {
+			message += "   🎯 暂无已完成交易\n"
+		}
+	}
+	message += "\n盈亏 = 期间内开仓的交易的净盈亏（未平仓的计入当前未实现盈亏），期初已持有的仓位不计入，不含出入金；收益率以期初权益为基数。"
+	return message
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+func TestLeaderboardCandidatesGroupAddressCase(t *testing.T) {
+	setupTestDB(t)
+	config.SuperAdminID = "1"
+	address := "0xAbCdEf0000000000000000000000000000000001"
+	walletMutex.Lock()
+	wallets["1_"+address] = WalletConfig{Address: address, Name: "管理员", ChatID: "1"}
+	wallets["2_"+strings.ToLower(address)] = WalletConfig{Address: strings.ToLower(address), Name: "用户", ChatID: "2"}
+	walletMutex.Unlock()
+
+	candidates := leaderboardCandidates("1")
+	if len(candidates) != 1 {
+		t.Fatalf("got %d entries, want 1", len(candidates))
+	}
+	if candidates[0].Subscribers != 2 || candidates[0].Wallet.Name != "管理员" {
+		t.Errorf("entry = %+v", candidates[0])
+	}
+}
+
+// 盈亏只统计期间内开仓的交易，期初已持有的仓位和期间内的入金都不计入
+func TestLeaderboardPnlCountsTradesOpenedInPeriod(t *testing.T) {
+	setupTestDB(t)
+	address := "0x7777777777777777777777777777777777777777"
+	now := time.Now()
+
+	position := func(coin, upnl string) hyperliquid.Position {
+		return hyperliquid.Position{Coin: coin, Szi: "1", PositionValue: "100", UnrealizedPnl: upnl}
+	}
+	recordAccountSnapshot(address, map[string]hyperliquid.Position{"BTC": position("BTC", "10"), "DOGE": position("DOGE", "300")},
+		AccountSummary{AccountValue: 1000}, now.Add(-48*time.Hour))
+	// 期间内入金 5000
+	recordAccountSnapshot(address, map[string]hyperliquid.Position{"BTC": position("BTC", "60"), "SOL": position("SOL", "40")},
+		AccountSummary{AccountValue: 6150}, now.Add(-time.Hour))
+
+	trades := []*Trade{
+		// 期间内开仓并平仓
+		{Address: address, Coin: "ETH", Long: true, OpenTime: now.Add(-30 * time.Hour), CloseTime: now.Add(-20 * time.Hour),
+			EntrySize: 1, EntryNotional: 100, ExitSize: 1, ExitNotional: 200, RealizedPnl: 105, Fees: 5, Fills: 2},
+		// 期间内开仓，仍未平仓
+		{Address: address, Coin: "SOL", Long: true, OpenTime: now.Add(-10 * time.Hour), EntrySize: 1, EntryNotional: 100, Fees: 2, Fills: 1},
+		// 期初已持有：期间内平仓的 DOGE 和仍未平仓的 BTC 都不计入
+		{Address: address, Coin: "DOGE", Long: true, OpenTime: now.Add(-50 * time.Hour), CloseTime: now.Add(-40 * time.Hour),
+			EntrySize: 1, EntryNotional: 100, ExitSize: 1, ExitNotional: 600, RealizedPnl: 500, Fills: 2},
+		{Address: address, Coin: "BTC", Long: true, OpenTime: now.Add(-72 * time.Hour), EntrySize: 1, EntryNotional: 100, Fills: 1},
+	}
+	if err := saveTrades(address, trades, FillCursor{}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := leaderboardEntry{Wallet: WalletConfig{Address: address}}
+	if err := fillLeaderboardEntry(&entry, now.Add(-historyPeriods["7d"])); err != nil {
+		t.Fatal(err)
+	}
+	// ETH 净盈亏 100 + SOL 未实现盈亏 40 - SOL 手续费 2
+	if !entry.HasHistory || math.Abs(entry.Pnl-138) > 1e-9 || math.Abs(entry.Roi-13.8) > 1e-9 {
+		t.Errorf("pnl = %.2f, roi = %.2f%%, want 138 and 13.8%%", entry.Pnl, entry.Roi)
+	}
+	// 胜率仍按期间内平仓的交易计算
+	if entry.Trades != 2 || entry.Wins != 2 {
+		t.Errorf("trades = %d, wins = %d", entry.Trades, entry.Wins)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+const markPriceTTL = 5 * time.Second
+
+// LiquidationAlertConfig 强平距离提醒配置，距离为标记价格到强平价格的百分比
+type LiquidationAlertConfig struct {
+	Levels             []float64 `json:"levels"`             // 提醒档位，例如 [20, 10, 5]
+	Hysteresis         float64   `json:"hysteresis"`         // 距离回升超过档位加该值后才会重新提醒
+	LiquidatedDistance float64   `json:"liquidatedDistance"` // 仓位消失前距离低于该值视为被强平
+}
+
+var (
+	markPrices         map[string]float64
+	markPricesUpdated  time.Time
+	markPriceMutex     sync.Mutex
+	liquidationMutex   sync.Mutex
+	liquidationLevels  = make(map[string]map[string]float64) // address -> coin -> 已提醒的档位
+	liquidationLastGap = make(map[string]map[string]float64) // address -> coin -> 上次的强平距离
+)
+
+func defaultLiquidationAlertConfig() *LiquidationAlertConfig {
+	return &LiquidationAlertConfig{
+		Levels:             []float64{20, 10, 5},
+		Hysteresis:         2,
+		LiquidatedDistance: 2,
+	}
+}
+
+// 获取标记价格，短时间内复用缓存；metaAndAssetCtxs 失败时退回 allMids
+func getMarkPrices() map[string]float64 {
+	markPriceMutex.Lock()
+	defer markPriceMutex.Unlock()
+
+	if markPrices != nil && time.Since(markPricesUpdated) < markPriceTTL {
+		return markPrices
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	prices := make(map[string]float64)
+	meta, assetCtxs, err := hlClient.MetaAndAssetCtxs(ctx)
+	if err == nil {
+		for i, asset := range meta.Universe {
+			if i >= len(assetCtxs) {
+				break
+			}
+			if px, err := strconv.ParseFloat(assetCtxs[i].MarkPx, 64); err == nil {
+				prices[asset.Name] = px
+			}
+		}
+	} else {
+		log.Printf("获取标记价格失败，改用中间价: %v", err)
+		mids, err := hlClient.AllMids(ctx)
+		if err != nil {
+			log.Printf("获取中间价失败: %v", err)
+			return markPrices
+		}
+		for coin, mid := range mids {
+			if px, err := strconv.ParseFloat(mid, 64); err == nil {
+				prices[coin] = px
+			}
+		}
+	}
+
+	markPrices = prices
+	markPricesUpdated = time.Now()
+	return markPrices
+}
+
+func loadLiquidationAlertsFromDB() error {
+	liquidationMutex.Lock()
+	defer liquidationMutex.Unlock()
+
+	rows, err := db.Query("SELECT address, coin, level FROM liquidation_alerts")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var address, coin string
+		var level float64
+		if err := rows.Scan(&address, &coin, &level); err != nil {
+			return err
+		}
+		if liquidationLevels[address] == nil {
+			liquidationLevels[address] = make(map[string]float64)
+		}
+		liquidationLevels[address][coin] = level
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	gapRows, err := db.Query("SELECT address, coin, gap FROM liquidation_gaps")
+	if err != nil {
+		return err
+	}
+	defer gapRows.Close()
+
+	for gapRows.Next() {
+		var address, coin string
+		var gap float64
+		if err := gapRows.Scan(&address, &coin, &gap); err != nil {
+			return err
+		}
+		if liquidationLastGap[address] == nil {
+			liquidationLastGap[address] = make(map[string]float64)
+		}
+		liquidationLastGap[address][coin] = gap
+	}
+	return gapRows.Err()
+}
+
+func saveLiquidationLevel(address, coin string, level float64) error {
+	if level == 0 {
+		_, err := db.Exec("DELETE FROM liquidation_alerts WHERE address = ? AND coin = ?", address, coin)
+		return err
+	}
+	_, err := db.Exec(`
+        INSERT OR REPLACE INTO liquidation_alerts (address, coin, level)
+        VALUES (?, ?, ?)
+    `, address, coin, level)
+	return err
+}
+
+// 记录上次的强平距离，exists 为 false 时删除。只有不超过 LiquidatedDistance 的距离需要保存到数据库，
+// 重启后仍能识别出停机期间被强平的仓位；调用方需持有 liquidationMutex
+func setLiquidationGap(address, coin string, lastGap map[string]float64, gap float64, exists bool) {
+	previous, had := lastGap[coin]
+	if exists {
+		lastGap[coin] = gap
+	} else {
+		delete(lastGap, coin)
+	}
+
+	threshold := config.LiquidationAlert.LiquidatedDistance
+	wasNear := had && previous <= threshold
+	isNear := exists && gap <= threshold
+	if !wasNear && !isNear {
+		return
+	}
+	var err error
+	if isNear {
+		_, err = db.Exec("INSERT OR REPLACE INTO liquidation_gaps (address, coin, gap) VALUES (?, ?, ?)", address, coin, gap)
+	} else {
+		_, err = db.Exec("DELETE FROM liquidation_gaps WHERE address = ? AND coin = ?", address, coin)
+	}
+	if err != nil {
+		log.Printf("保存强平距离失败 %s: %v", address, err)
+	}
+}
+
+func clearLiquidationState(address string) {
+	liquidationMutex.Lock()
+	defer liquidationMutex.Unlock()
+
+	delete(liquidationLevels, address)
+	delete(liquidationLastGap, address)
+	if _, err := db.Exec("DELETE FROM liquidation_alerts WHERE address = ?", address); err != nil {
+		log.Printf("删除强平提醒状态失败 %s: %v", address, err)
+	}
+	if _, err := db.Exec("DELETE FROM liquidation_gaps WHERE address = ?", address); err != nil {
+		log.Printf("删除强平距离失败 %s: %v", address, err)
+	}
+}
+
+// 标记价格到强平价格的距离百分比，没有强平价格时返回 false
+func liquidationDistance(position hyperliquid.Position, markPx float64) (float64, bool) {
+	liquidationPx, err := strconv.ParseFloat(position.LiquidationPx, 64)
+	if err != nil || liquidationPx <= 0 || markPx <= 0 {
+		return 0, false
+	}
+	szi, _ := strconv.ParseFloat(position.Szi, 64)
+	if szi > 0 {
+		return (markPx - liquidationPx) / markPx * 100, true
+	}
+	return (liquidationPx - markPx) / markPx * 100, true
+}
+
+func checkLiquidationRisk(address string, subscribers []WalletConfig, positions map[string]hyperliquid.Position) {
+	cfg := config.LiquidationAlert
+	if len(cfg.Levels) == 0 || len(subscribers) == 0 {
+		return
+	}
+	levels := append([]float64(nil), cfg.Levels...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(levels)))
+
+	var prices map[string]float64
+	if len(positions) > 0 {
+		prices = getMarkPrices()
+	}
+
+	// 发送提醒可能阻塞，在释放 liquidationMutex 之后进行
+	alerts, priority := detectLiquidationAlerts(address, positions, prices, levels)
+	if len(alerts) == 0 {
+		return
+	}
+	sort.Strings(alerts)
+	for _, wallet := range subscribers {
+		timeStamp := time.Now().Format("2006-01-02 15:04:05")
+		message := fmt.Sprintf("🚨 HyperLiquid强平风险 - %s (%s)\n\n", wallet.Name, timeStamp)
+		message += fmt.Sprintf("💼 账户地址: %s\n\n", shortenAddress(wallet.Address))
+		for _, alert := range alerts {
+			message += alert
+		}
+		if err := notifyWallet(wallet, Notification{Title: "HyperLiquid强平风险", Text: message, Priority: priority}); err != nil {
+			log.Printf("发送强平提醒失败 %s (ChatID: %s): %v", address, wallet.ChatID, err)
+		}
+	}
+}
+
+// 更新各仓位的提醒档位和距离，返回需要发送的提醒；levels 按从远到近排序
+func detectLiquidationAlerts(address string, positions map[string]hyperliquid.Position, prices map[string]float64, levels []float64) ([]string, NotificationPriority) {
+	cfg := config.LiquidationAlert
+	liquidationMutex.Lock()
+	defer liquidationMutex.Unlock()
+
+	if liquidationLevels[address] == nil {
+		liquidationLevels[address] = make(map[string]float64)
+	}
+	if liquidationLastGap[address] == nil {
+		liquidationLastGap[address] = make(map[string]float64)
+	}
+	alerted := liquidationLevels[address]
+	lastGap := liquidationLastGap[address]
+
+	var alerts []string
+	priority := PriorityHigh
+
+	// 仓位消失且之前已非常接近强平价格，视为被强平
+	for coin, gap := range lastGap {
+		if _, exists := positions[coin]; exists {
+			continue
+		}
+		if gap <= cfg.LiquidatedDistance {
+			alerts = append(alerts, fmt.Sprintf("💥 %s 仓位已消失，消失前距强平价格仅 %.2f%%，疑似已被强平\n\n", coin, gap))
+			priority = PriorityUrgent
+		}
+		setLiquidationGap(address, coin, lastGap, 0, false)
+		if _, exists := alerted[coin]; exists {
+			delete(alerted, coin)
+			if err := saveLiquidationLevel(address, coin, 0); err != nil {
+				log.Printf("保存强平提醒状态失败 %s: %v", address, err)
+			}
+		}
+	}
+
+	for coin, position := range positions {
+		markPx, exists := prices[coin]
+		if !exists {
+			markPx = positionPrice(position)
+		}
+		gap, ok := liquidationDistance(position, markPx)
+		if !ok {
+			setLiquidationGap(address, coin, lastGap, 0, false)
+			continue
+		}
+		setLiquidationGap(address, coin, lastGap, gap, true)
+
+		// 当前所在的最深档位
+		breached := 0.0
+		remaining := 0
+		for i, level := range levels {
+			if gap <= level {
+				breached = level
+				remaining = len(levels) - 1 - i
+			}
+		}
+
+		previous := alerted[coin]
+		next := previous
+		if breached > 0 && (previous == 0 || breached < previous) {
+			alerts = append(alerts, formatLiquidationAlert(position, markPx, gap, breached, remaining))
+			if remaining == 0 {
+				priority = PriorityUrgent
+			}
+			next = breached
+		} else if previous > 0 && gap > previous+cfg.Hysteresis {
+			// 距离明显回升后放宽到当前所在档位，允许再次提醒
+			next = 0
+			for _, level := range levels {
+				if gap <= level+cfg.Hysteresis {
+					next = level
+				}
+			}
+		}
+
+		if next != previous {
+			if next == 0 {
+				delete(alerted, coin)
+			} else {
+				alerted[coin] = next
+			}
+			if err := saveLiquidationLevel(address, coin, next); err != nil {
+				log.Printf("保存强平提醒状态失败 %s: %v", address, err)
+			}
+		}
+	}
+
+	return alerts, priority
+}
+
+// remaining 为比当前档位更深的档位数量，0 表示已到最后一档
+func formatLiquidationAlert(position hyperliquid.Position, markPx, gap, level float64, remaining int) string {
+	liquidationPx, _ := strconv.ParseFloat(position.LiquidationPx, 64)
+	szi, _ := strconv.ParseFloat(position.Szi, 64)
+	direction := "多头"
+	if szi < 0 {
+		direction = "空头"
+		szi = -szi
+	}
+
+	label := "⚠️ 注意"
+	switch remaining {
+	case 0:
+		label = "🔴 危险"
+	case 1:
+		label = "🟠 警告"
+	}
+
+	message := fmt.Sprintf("%s: %s (%s) 距强平价格低于 %g%%\n", label, position.Coin, direction, level)
+	message += fmt.Sprintf("   📈 仓位大小: %.5f\n", szi)
+	message += fmt.Sprintf("   🏷️ 标记价格: $%.4f\n", markPx)
+	message += fmt.Sprintf("   ⚠️ 强平价格: $%.4f\n", liquidationPx)
+	message += fmt.Sprintf("   📏 当前距离: %.2f%%\n\n", math.Max(gap, 0))
+	return message
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+// 仓位在重启前已非常接近强平价格，重启后发现仓位消失时仍应提醒疑似被强平
+func TestLiquidatedPositionDetectedAfterRestart(t *testing.T) {
+	setupTestDB(t)
+	notifier := registerTestNotifier(t)
+	config.LiquidationAlert = defaultLiquidationAlertConfig()
+	resetLiquidationState := func() {
+		liquidationMutex.Lock()
+		liquidationLevels = make(map[string]map[string]float64)
+		liquidationLastGap = make(map[string]map[string]float64)
+		liquidationMutex.Unlock()
+	}
+	resetLiquidationState()
+	t.Cleanup(resetLiquidationState)
+
+	address := "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	subscribers := []WalletConfig{{Address: address, Name: "测试", ChatID: "1", Channel: testChannel}}
+	positions := map[string]hyperliquid.Position{
+		// 标记价格 100，强平价格 99，距离 1%
+		"BTC": {Coin: "BTC", Szi: "1", PositionValue: "100", LiquidationPx: "99"},
+		// 距离 50%，无需保存
+		"ETH": {Coin: "ETH", Szi: "1", PositionValue: "100", LiquidationPx: "50"},
+	}
+	markPriceMutex.Lock()
+	markPrices = map[string]float64{"BTC": 100, "ETH": 100}
+	markPricesUpdated = time.Now()
+	markPriceMutex.Unlock()
+	t.Cleanup(func() {
+		markPriceMutex.Lock()
+		markPrices = nil
+		markPriceMutex.Unlock()
+	})
+
+	checkLiquidationRisk(address, subscribers, positions)
+	if notifier.count() != 1 {
+		t.Fatalf("got %d notifications, want the level alert", notifier.count())
+	}
+
+	var saved int
+	if err := db.QueryRow("SELECT COUNT(*) FROM liquidation_gaps WHERE address = ?", address).Scan(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved != 1 {
+		t.Fatalf("saved %d gaps, want only the BTC gap", saved)
+	}
+
+	// 模拟重启
+	resetLiquidationState()
+	if err := loadLiquidationAlertsFromDB(); err != nil {
+		t.Fatal(err)
+	}
+	checkLiquidationRisk(address, subscribers, map[string]hyperliquid.Position{"ETH": positions["ETH"]})
+	if notifier.count() != 2 {
+		t.Fatalf("got %d notifications, want a liquidation alert after restart", notifier.count())
+	}
+	if text := notifier.notifications[1].Text; !strings.Contains(text, "BTC 仓位已消失") || notifier.notifications[1].Priority != PriorityUrgent {
+		t.Errorf("unexpected alert:\n%s", text)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM liquidation_gaps WHERE address = ?", address).Scan(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved != 0 {
+		t.Errorf("gap of the vanished position not deleted")
+	}
+}
+
+// 发送强平提醒时不应持有 liquidationMutex
+func TestLiquidationAlertsSentAfterLiquidationMutex(t *testing.T) {
+	setupTestDB(t)
+	notifier := &blockingNotifier{called: make(chan struct{}), release: make(chan struct{})}
+	registerNotifier(testChannel, notifier)
+	config.LiquidationAlert = defaultLiquidationAlertConfig()
+	t.Cleanup(func() {
+		delete(notifiers, testChannel)
+		liquidationMutex.Lock()
+		liquidationLevels = make(map[string]map[string]float64)
+		liquidationLastGap = make(map[string]map[string]float64)
+		liquidationMutex.Unlock()
+	})
+
+	address := "0xcccccccccccccccccccccccccccccccccccccccc"
+	subscribers := []WalletConfig{{Address: address, Name: "测试", ChatID: "1", Channel: testChannel}}
+	positions := map[string]hyperliquid.Position{
+		"BTC": {Coin: "BTC", Szi: "1", PositionValue: "100", LiquidationPx: "99"},
+	}
+	markPriceMutex.Lock()
+	markPrices = map[string]float64{"BTC": 100}
+	markPricesUpdated = time.Now()
+	markPriceMutex.Unlock()
+	t.Cleanup(func() {
+		markPriceMutex.Lock()
+		markPrices = nil
+		markPriceMutex.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		checkLiquidationRisk(address, subscribers, positions)
+	}()
+	<-notifier.called
+
+	locked := make(chan struct{})
+	go func() {
+		liquidationMutex.Lock()
+		liquidationMutex.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		close(notifier.release)
+		t.Fatal("liquidationMutex held while sending alerts")
+	}
+	close(notifier.release)
+	<-done
+}
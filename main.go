@@ -1,14 +1,11 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"math"
-	"net/http"
 	"os"
 	"regexp"
 	"strconv"
@@ -16,97 +13,92 @@ import (
 	"sync"
 	"time"
 
+	"position-monitor/hyperliquid"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-type ClearinghouseRequest struct {
-	Type string `json:"type"`
-	User string `json:"user"`
-}
-
-type Position struct {
-	Coin     string `json:"coin"`
-	Szi      string `json:"szi"`
-	Leverage struct {
-		Type   string `json:"type"`
-		Value  int    `json:"value"`
-		RawUsd string `json:"rawUsd"`
-	} `json:"leverage"`
-	EntryPx        string `json:"entryPx"`
-	PositionValue  string `json:"positionValue"`
-	UnrealizedPnl  string `json:"unrealizedPnl"`
-	ReturnOnEquity string `json:"returnOnEquity"`
-	LiquidationPx  string `json:"liquidationPx"`
-	MarginUsed     string `json:"marginUsed"`
-	MaxLeverage    int    `json:"maxLeverage"`
-	CumFunding     struct {
-		AllTime     string `json:"allTime"`
-		SinceOpen   string `json:"sinceOpen"`
-		SinceChange string `json:"sinceChange"`
-	} `json:"cumFunding"`
-}
-
-type AssetPosition struct {
-	Type     string   `json:"type"`
-	Position Position `json:"position"`
-}
-
-type Response struct {
-	MarginSummary struct {
-		AccountValue    string `json:"accountValue"`
-		TotalNtlPos     string `json:"totalNtlPos"`
-		TotalRawUsd     string `json:"totalRawUsd"`
-		TotalMarginUsed string `json:"totalMarginUsed"`
-	} `json:"marginSummary"`
-	CrossMarginSummary struct {
-		AccountValue    string `json:"accountValue"`
-		TotalNtlPos     string `json:"totalNtlPos"`
-		TotalRawUsd     string `json:"totalRawUsd"`
-		TotalMarginUsed string `json:"totalMarginUsed"`
-	} `json:"crossMarginSummary"`
-	CrossMaintenanceMarginUsed string          `json:"crossMaintenanceMarginUsed"`
-	Withdrawable               string          `json:"withdrawable"`
-	AssetPositions             []AssetPosition `json:"assetPositions"`
-	Time                       int64           `json:"time"`
-}
-
 type Config struct {
 	TelegramToken   string `json:"telegramToken"`
 	PollingInterval int    `json:"pollingInterval"`
 	SuperAdminID    string `json:"superAdminID"`
+	ApiURL          string `json:"apiURL"`
+	UseWebSocket    bool   `json:"useWebSocket"`
+	WsURL           string `json:"wsURL"`
+	NotifyFills     bool   `json:"notifyFills"`
+	TrackOrders     bool   `json:"trackOrders"`
+
+	AccountValueAlert *ChangeThreshold `json:"accountValueAlert"`
+	WithdrawableAlert *ChangeThreshold `json:"withdrawableAlert"`
+	MarginUsedAlert   *ChangeThreshold `json:"marginUsedAlert"`
+
+	LiquidationAlert *LiquidationAlertConfig `json:"liquidationAlert"`
+
+	SMTP *SMTPConfig `json:"smtp"`
+}
+
+// ChangeThreshold 变化幅度达到百分比或绝对金额任一阈值即提醒，0 表示不启用该项
+type ChangeThreshold struct {
+	Percent float64 `json:"percent"`
+	Usd     float64 `json:"usd"`
+}
+
+// 账户层面的汇总数据
+type AccountSummary struct {
+	AccountValue    float64 `json:"accountValue"`
+	Withdrawable    float64 `json:"withdrawable"`
+	TotalMarginUsed float64 `json:"totalMarginUsed"`
 }
 
 type WalletConfig struct {
-	Address string
-	Name    string
-	ChatID  string
+	Address    string
+	Name       string
+	ChatID     string
+	Settings   AlertSettings
+	Channel    string    // 通知渠道，为空表示发到 ChatID 所在的 Telegram 聊天
+	Target     string    // 渠道内的目标，例如 Discord Webhook 地址
+	Secret     string    // 渠道的签名密钥或访问令牌（Webhook、飞书、钉钉、Matrix、ntfy、Gotify）
+	Digest     string    // 邮件摘要周期，为空表示立即发送
+	Muted      bool      // 静音时照常更新基线，但不发送提醒
+	MutedUntil time.Time // 暂停提醒的截止时间
+	Tags       []string
+	Report     ReportSchedule
 }
 
+// AccountState 存入 accountStates 或 subscriptionStates 后不再原地修改，更新时通过
+// storeAccountState/storeSubscriptionState 换成新的副本。两个 map 由 walletMutex 保护，
+// 取得指针后可以不加锁读取；monitorMutex 负责串行化更新，避免轮询和推送互相覆盖。
 type AccountState struct {
-	LastPositions    map[string]Position
+	LastPositions    map[string]hyperliquid.Position
 	LastAccountValue float64
+	LastWithdrawable float64
+	LastMarginUsed   float64
+	LastOrders       map[int64]hyperliquid.OpenOrder // 为 nil 表示尚未获取过挂单
 }
 
 const (
-	ApiEndpoint = "https://api.hyperliquid.xyz/info"
-	ConfigPath  = "config.json"
-	DBPath      = "position-monitor.db"
+	ConfigPath = "config.json"
+	DBPath     = "position-monitor.db"
 )
 
 var (
-	accountStates   = make(map[string]*AccountState) // 键为 address
-	wallets         = make(map[string]WalletConfig)  // 键为 chatID_address
-	walletMutex     sync.Mutex
-	bot             *tgbotapi.BotAPI
-	db              *sql.DB
-	authorizedUsers = make(map[string]bool)
-	config          *Config
+	accountStates      = make(map[string]*AccountState) // 键为 address
+	subscriptionStates = make(map[string]*AccountState) // 键为 chatID_address，每个订阅独立的通知基线
+	wallets            = make(map[string]WalletConfig)  // 键为 chatID_address
+	walletMutex        sync.Mutex
+	bot                *tgbotapi.BotAPI
+	db                 *sql.DB
+	authorizedUsers    = make(map[string]bool)
+	config             *Config
+	hlClient           *hyperliquid.Client
+	wsClient           *hyperliquid.WSClient
+	monitorMutex       sync.Mutex
 )
 
 func main() {
 	var err error
-	db, err = initDB()
+	db, err = initDB(DBPath)
 	if err != nil {
 		log.Fatalf("初始化数据库失败: %v", err)
 	}
@@ -117,12 +109,31 @@ func main() {
 		log.Fatalf("加载配置失败: %v", err)
 	}
 
+	hlClient = hyperliquid.NewClient(hyperliquid.WithBaseURL(config.ApiURL))
+
 	bot, err = tgbotapi.NewBotAPI(config.TelegramToken)
 	if err != nil {
 		log.Fatalf("初始化Telegram Bot失败: %v", err)
 	}
 	bot.Debug = false
 	log.Printf("Telegram Bot已授权: %s", bot.Self.UserName)
+	telegramOutbox = NewTelegramOutbox(bot.Send)
+	go telegramOutbox.Run()
+
+	registerNotifier(ChannelTelegram, TelegramNotifier{})
+	registerNotifier(ChannelDiscord, NewDiscordNotifier())
+	registerNotifier(ChannelWebhook, NewWebhookNotifier())
+	registerNotifier(ChannelFeishu, NewFeishuNotifier())
+	registerNotifier(ChannelDingTalk, NewDingTalkNotifier())
+	registerNotifier(ChannelWeCom, NewWeComNotifier())
+	registerNotifier(ChannelMatrix, NewMatrixNotifier())
+	registerNotifier(ChannelNtfy, NewNtfyNotifier())
+	registerNotifier(ChannelGotify, NewGotifyNotifier())
+	if config.SMTP != nil {
+		emailNotifier := NewEmailNotifier(*config.SMTP)
+		registerNotifier(ChannelEmail, emailNotifier)
+		go runEmailDigests(emailNotifier)
+	}
 
 	authorizedUsers[config.SuperAdminID] = true
 
@@ -132,17 +143,40 @@ func main() {
 	if err := loadAuthorizedUsersFromDB(); err != nil {
 		log.Printf("加载授权用户失败: %v", err)
 	}
+	if err := loadLiquidationAlertsFromDB(); err != nil {
+		log.Printf("加载强平提醒状态失败: %v", err)
+	}
+	if err := loadQuietHoursFromDB(); err != nil {
+		log.Printf("加载静默时段失败: %v", err)
+	}
+	go runQuietHoursFlusher()
+	go runSnapshotMaintenance()
+	go runReportScheduler()
+	go runTradeSync()
 
 	go handleTelegramUpdates(config)
 
+	if config.UseWebSocket {
+		wsClient = hyperliquid.NewWSClient(config.WsURL)
+		syncWebSocketSubscriptions()
+		go wsClient.Run(context.Background(), handleWebSocketMessage)
+	}
+
 	for {
 		time.Sleep(time.Duration(config.PollingInterval) * time.Second)
+		if wsClient != nil {
+			syncWebSocketSubscriptions()
+			// WebSocket 正常时由推送驱动，断开时退回轮询
+			if wsClient.Connected() {
+				continue
+			}
+		}
 		monitorAllWallets()
 	}
 }
 
-func initDB() (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", DBPath)
+func initDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return nil, fmt.Errorf("打开数据库失败: %v", err)
 	}
@@ -159,6 +193,41 @@ func initDB() (*sql.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("创建订阅表失败: %v", err)
 	}
+	if err := migrateSubscriptionSettings(db); err != nil {
+		return nil, fmt.Errorf("升级订阅表失败: %v", err)
+	}
+	if err := addColumnIfMissing(db, "subscriptions", "channel", "TEXT NOT NULL DEFAULT 'telegram'"); err != nil {
+		return nil, fmt.Errorf("升级订阅表失败: %v", err)
+	}
+	if err := addColumnIfMissing(db, "subscriptions", "target", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, fmt.Errorf("升级订阅表失败: %v", err)
+	}
+	if err := addColumnIfMissing(db, "subscriptions", "secret", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, fmt.Errorf("升级订阅表失败: %v", err)
+	}
+	if err := addColumnIfMissing(db, "subscriptions", "digest", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, fmt.Errorf("升级订阅表失败: %v", err)
+	}
+	if err := addColumnIfMissing(db, "subscriptions", "muted", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, fmt.Errorf("升级订阅表失败: %v", err)
+	}
+	if err := addColumnIfMissing(db, "subscriptions", "tags", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, fmt.Errorf("升级订阅表失败: %v", err)
+	}
+	if err := addColumnIfMissing(db, "subscriptions", "muted_until", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, fmt.Errorf("升级订阅表失败: %v", err)
+	}
+	for _, column := range [][2]string{
+		{"report_period", "TEXT NOT NULL DEFAULT ''"},
+		{"report_minute", "INTEGER NOT NULL DEFAULT 0"},
+		{"report_weekday", "INTEGER NOT NULL DEFAULT 1"},
+		{"report_timezone", "TEXT NOT NULL DEFAULT ''"},
+		{"report_last_sent", "INTEGER NOT NULL DEFAULT 0"},
+	} {
+		if err := addColumnIfMissing(db, "subscriptions", column[0], column[1]); err != nil {
+			return nil, fmt.Errorf("升级订阅表失败: %v", err)
+		}
+	}
 
 	_, err = db.Exec(`
         CREATE TABLE IF NOT EXISTS account_states (
@@ -170,6 +239,160 @@ func initDB() (*sql.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("创建状态表失败: %v", err)
 	}
+	for column, definition := range map[string]string{
+		"orders":       "TEXT",
+		"withdrawable": "REAL NOT NULL DEFAULT 0",
+		"margin_used":  "REAL NOT NULL DEFAULT 0",
+	} {
+		if err := addColumnIfMissing(db, "account_states", column, definition); err != nil {
+			return nil, fmt.Errorf("升级状态表失败: %v", err)
+		}
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS subscription_states (
+            chat_id TEXT NOT NULL,
+            address TEXT NOT NULL,
+            account_value REAL,
+            withdrawable REAL,
+            margin_used REAL,
+            positions TEXT,
+            PRIMARY KEY(chat_id, address)
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("创建订阅状态表失败: %v", err)
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS liquidation_alerts (
+            address TEXT NOT NULL,
+            coin TEXT NOT NULL,
+            level REAL NOT NULL,
+            PRIMARY KEY(address, coin)
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("创建强平提醒表失败: %v", err)
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS liquidation_gaps (
+            address TEXT NOT NULL,
+            coin TEXT NOT NULL,
+            gap REAL NOT NULL,
+            PRIMARY KEY(address, coin)
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("创建强平距离表失败: %v", err)
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+            delivery_id TEXT PRIMARY KEY,
+            url TEXT NOT NULL,
+            payload TEXT NOT NULL,
+            last_error TEXT,
+            attempts INTEGER NOT NULL,
+            created_at INTEGER NOT NULL
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("创建Webhook死信表失败: %v", err)
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS email_digest_queue (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            recipient TEXT NOT NULL,
+            digest TEXT NOT NULL,
+            chat_id TEXT NOT NULL,
+            address TEXT NOT NULL,
+            name TEXT NOT NULL,
+            title TEXT NOT NULL,
+            text TEXT NOT NULL,
+            created_at INTEGER NOT NULL
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("创建邮件摘要队列表失败: %v", err)
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS quiet_hours (
+            chat_id TEXT PRIMARY KEY,
+            start_minute INTEGER NOT NULL,
+            end_minute INTEGER NOT NULL,
+            timezone TEXT NOT NULL
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("创建静默时段表失败: %v", err)
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS quiet_queue (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            chat_id TEXT NOT NULL,
+            address TEXT NOT NULL,
+            title TEXT NOT NULL,
+            text TEXT NOT NULL,
+            created_at INTEGER NOT NULL
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("创建静默队列表失败: %v", err)
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS account_snapshots (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            address TEXT NOT NULL,
+            time INTEGER NOT NULL,
+            account_value REAL NOT NULL,
+            withdrawable REAL NOT NULL,
+            margin_used REAL NOT NULL,
+            unrealized_pnl REAL NOT NULL,
+            long_notional REAL NOT NULL,
+            short_notional REAL NOT NULL,
+            positions TEXT NOT NULL
+        );
+        CREATE INDEX IF NOT EXISTS idx_account_snapshots_address_time ON account_snapshots (address, time)
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("创建账户快照表失败: %v", err)
+	}
+
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS trades (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            address TEXT NOT NULL,
+            coin TEXT NOT NULL,
+            direction TEXT NOT NULL,
+            open_time INTEGER NOT NULL,
+            close_time INTEGER NOT NULL,
+            max_size REAL NOT NULL,
+            entry_size REAL NOT NULL,
+            entry_notional REAL NOT NULL,
+            exit_size REAL NOT NULL,
+            exit_notional REAL NOT NULL,
+            realized_pnl REAL NOT NULL,
+            fees REAL NOT NULL,
+            fills INTEGER NOT NULL,
+            UNIQUE(address, coin, open_time)
+        );
+        CREATE TABLE IF NOT EXISTS trade_cursors (
+            address TEXT PRIMARY KEY,
+            last_fill_time INTEGER NOT NULL
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("创建交易记录表失败: %v", err)
+	}
+	if err := addColumnIfMissing(db, "trade_cursors", "last_tids", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, fmt.Errorf("升级交易游标表失败: %v", err)
+	}
 
 	_, err = db.Exec(`
         CREATE TABLE IF NOT EXISTS authorized_users (
@@ -180,9 +403,49 @@ func initDB() (*sql.DB, error) {
 		return nil, fmt.Errorf("创建授权用户表失败: %v", err)
 	}
 
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS fill_cursors (
+            address TEXT PRIMARY KEY,
+            last_fill_time INTEGER NOT NULL
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("创建成交游标表失败: %v", err)
+	}
+	if err := addColumnIfMissing(db, "fill_cursors", "last_tids", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return nil, fmt.Errorf("升级成交游标表失败: %v", err)
+	}
+
 	return db, nil
 }
 
+// 为旧版本数据库补充新增的列
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 func loadConfig(path string) (*Config, error) {
 	file, err := os.ReadFile(path)
 	if err != nil {
@@ -197,6 +460,12 @@ func loadConfig(path string) (*Config, error) {
 	if config.PollingInterval <= 0 {
 		config.PollingInterval = 30
 	}
+	if config.AccountValueAlert == nil {
+		config.AccountValueAlert = &ChangeThreshold{Percent: 1}
+	}
+	if config.LiquidationAlert == nil {
+		config.LiquidationAlert = defaultLiquidationAlertConfig()
+	}
 
 	return &config, nil
 }
@@ -208,6 +477,10 @@ func handleTelegramUpdates(config *Config) {
 	updates := bot.GetUpdatesChan(u)
 
 	for update := range updates {
+		if update.CallbackQuery != nil {
+			handleCallbackQuery(update.CallbackQuery)
+			continue
+		}
 		if update.Message == nil {
 			continue
 		}
@@ -258,8 +531,57 @@ func handleTelegramUpdates(config *Config) {
 			}
 			subscribeWallet(chatID, address, name)
 
-		case msgText == "/list":
-			listSubscriptions(chatID)
+		case strings.HasPrefix(msgText, "/target"):
+			if !isAuthorized(chatID) {
+				sendMessage(chatID, "您没有权限设置提醒渠道。请联系超级管理员 @imliyi 授权。")
+				continue
+			}
+			handleTargetCommand(chatID, msgText)
+
+		case strings.HasPrefix(msgText, "/settings"):
+			handleSettingsCommand(chatID, msgText)
+
+		case strings.HasPrefix(msgText, "/status"):
+			handleStatusCommand(chatID, msgText)
+
+		case strings.HasPrefix(msgText, "/history"):
+			handleHistoryCommand(chatID, msgText)
+
+		case strings.HasPrefix(msgText, "/chart"):
+			handleChartCommand(chatID, msgText)
+
+		case strings.HasPrefix(msgText, "/report"):
+			handleReportCommand(chatID, msgText)
+
+		case strings.HasPrefix(msgText, "/stats"):
+			handleStatsCommand(chatID, msgText)
+
+		case strings.HasPrefix(msgText, "/leaderboard"):
+			handleLeaderboardCommand(chatID, msgText)
+
+		case msgText == "/list" || strings.HasPrefix(msgText, "/list "):
+			listSubscriptions(chatID, strings.ToLower(strings.TrimSpace(strings.TrimPrefix(msgText, "/list"))))
+
+		case strings.HasPrefix(msgText, "/rename"):
+			handleRenameCommand(chatID, msgText)
+
+		case msgText == "/tags":
+			handleTagsCommand(chatID)
+
+		case strings.HasPrefix(msgText, "/tag"):
+			handleTagCommand(chatID, msgText, true)
+
+		case strings.HasPrefix(msgText, "/untag"):
+			handleTagCommand(chatID, msgText, false)
+
+		case strings.HasPrefix(msgText, "/mute"):
+			handleMuteCommand(chatID, msgText, true)
+
+		case strings.HasPrefix(msgText, "/unmute"):
+			handleMuteCommand(chatID, msgText, false)
+
+		case strings.HasPrefix(msgText, "/quiet"):
+			handleQuietCommand(chatID, msgText)
 
 		case strings.HasPrefix(msgText, "/unsubscribe"):
 			parts := strings.SplitN(msgText, " ", 2)
@@ -274,8 +596,11 @@ func handleTelegramUpdates(config *Config) {
 			unsubscribeWallet(chatID, parts[1])
 
 		case msgText == "/start" || msgText == "/help":
-			message := "欢迎使用 Position Monitor 监控机器人!\n\n命令:\n/myid - 获取您的Chat ID\n/subscribe <地址> [名称] - 订阅一个地址（需要授权）\n/unsubscribe <地址> - 取消订阅\n/list - 查看已订阅地址\n\n超级管理员命令:\n/authorize <chat_id> - 授权用户\n/deauthorize <chat_id> - 取消授权"
+			message := "欢迎使用 Position Monitor 监控机器人!\n\n命令:\n/myid - 获取您的Chat ID\n/subscribe <地址> [名称] - 订阅一个地址（需要授权）\n/unsubscribe <地址> - 取消订阅\n/list [#标签] - 查看并管理已订阅地址\n/status [地址|名称|#标签] - 查看账户实时状态\n/history <地址|名称|#标签> [24h|7d|30d] - 查看账户价值和敞口的历史变化\n/chart <地址|名称|#标签> [24h|7d|30d] - 生成账户价值、仓位和盈亏图表\n/report <地址|名称|#标签> <daily|weekly> ... - 设置每日/每周报告（发送 /report 查看用法）\n/stats <地址|名称|#标签> [7d|30d|all] - 查看往返交易统计（平均R = 每笔平均净盈亏 ÷ 亏损交易的平均亏损）\n/leaderboard [24h|7d|30d] [pnl|roi|winrate|drawdown] - 已订阅地址排行榜（超级管理员查看全部地址）\n/rename <地址> <名称> - 修改订阅名称\n/tag <地址|名称|#标签> #标签 - 添加标签（/untag 移除，/tags 查看全部）\n/mute <地址|名称|#标签> [时长] - 静音提醒，如 /mute #scalpers 2h（/unmute 取消）\n/quiet 23:00-07:00 [时区] - 设置静默时段，期间的提醒结束后汇总发送\n/settings <地址> - 查看或修改提醒阈值\n/target <地址> <渠道> ... - 设置提醒发送渠道（发送 /target 查看全部渠道）\n\n超级管理员命令:\n/authorize <chat_id> - 授权用户\n/deauthorize <chat_id> - 取消授权"
 			sendMessage(chatID, message)
+
+		default:
+			handlePendingRename(chatID, update.Message)
 		}
 	}
 }
@@ -284,44 +609,83 @@ func loadSubscriptionsFromDB() error {
 	walletMutex.Lock()
 	defer walletMutex.Unlock()
 
-	rows, err := db.Query("SELECT chat_id, address, name FROM subscriptions")
+	rows, err := db.Query("SELECT chat_id, address, name, channel, target, secret, digest, muted, muted_until, tags, " +
+		"report_period, report_minute, report_weekday, report_timezone, report_last_sent, " + settingsColumns() + " FROM subscriptions")
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var chatID, address, name string
-		if err := rows.Scan(&chatID, &address, &name); err != nil {
+		var chatID, address, name, channel, target, secret, digest, tags string
+		var muted bool
+		var mutedUntil int64
+		var reportPeriod, reportTimezone string
+		var reportMinute, reportWeekday int
+		var reportLastSent int64
+		settingValues := make([]sql.NullFloat64, len(alertSettingItems))
+		if err := rows.Scan(append([]any{&chatID, &address, &name, &channel, &target, &secret, &digest, &muted, &mutedUntil, &tags,
+			&reportPeriod, &reportMinute, &reportWeekday, &reportTimezone, &reportLastSent}, settingsScanTargets(settingValues)...)...); err != nil {
 			return err
 		}
 		key := chatID + "_" + address
-		wallets[key] = WalletConfig{
-			Address: address,
-			Name:    name,
-			ChatID:  chatID,
+		wallet := WalletConfig{
+			Address:  address,
+			Name:     name,
+			ChatID:   chatID,
+			Settings: settingsFromNullFloats(settingValues),
+			Channel:  channel,
+			Target:   target,
+			Secret:   secret,
+			Digest:   digest,
+			Muted:    muted,
+			Tags:     parseTags(tags),
+			Report:   reportScheduleFromDB(reportPeriod, reportMinute, reportWeekday, reportTimezone, reportLastSent),
+		}
+		if mutedUntil > 0 {
+			wallet.MutedUntil = time.Unix(mutedUntil, 0)
+		}
+		wallets[key] = wallet
+
+		subscriptionState, err := loadSubscriptionStateFromDB(chatID, address)
+		if err != nil {
+			return err
+		}
+		if subscriptionState != nil {
+			subscriptionStates[key] = subscriptionState
 		}
 
 		// 只加载一次状态
 		if _, exists := accountStates[address]; !exists {
-			var accountValue float64
+			var accountValue, withdrawable, marginUsed float64
 			var positionsJSON string
-			err := db.QueryRow("SELECT account_value, positions FROM account_states WHERE address = ?", address).
-				Scan(&accountValue, &positionsJSON)
+			var ordersJSON sql.NullString
+			err := db.QueryRow("SELECT account_value, withdrawable, margin_used, positions, orders FROM account_states WHERE address = ?", address).
+				Scan(&accountValue, &withdrawable, &marginUsed, &positionsJSON, &ordersJSON)
 			if err != nil && err != sql.ErrNoRows {
 				return err
 			}
 
-			positions := make(map[string]Position)
+			positions := make(map[string]hyperliquid.Position)
 			if positionsJSON != "" {
 				if err := json.Unmarshal([]byte(positionsJSON), &positions); err != nil {
 					return err
 				}
 			}
 
+			var orders map[int64]hyperliquid.OpenOrder
+			if ordersJSON.Valid && ordersJSON.String != "" && ordersJSON.String != "null" {
+				if err := json.Unmarshal([]byte(ordersJSON.String), &orders); err != nil {
+					return err
+				}
+			}
+
 			accountStates[address] = &AccountState{
 				LastPositions:    positions,
 				LastAccountValue: accountValue,
+				LastWithdrawable: withdrawable,
+				LastMarginUsed:   marginUsed,
+				LastOrders:       orders,
 			}
 		}
 	}
@@ -369,11 +733,54 @@ func saveAccountStateToDB(address string, state *AccountState) error {
 	if err != nil {
 		return err
 	}
+	ordersJSON, err := json.Marshal(state.LastOrders)
+	if err != nil {
+		return err
+	}
 
 	_, err = db.Exec(`
-        INSERT OR REPLACE INTO account_states (address, account_value, positions)
-        VALUES (?, ?, ?)
-    `, address, state.LastAccountValue, string(positionsJSON))
+        INSERT OR REPLACE INTO account_states (address, account_value, withdrawable, margin_used, positions, orders)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `, address, state.LastAccountValue, state.LastWithdrawable, state.LastMarginUsed, string(positionsJSON), string(ordersJSON))
+	return err
+}
+
+func loadSubscriptionStateFromDB(chatID, address string) (*AccountState, error) {
+	var accountValue, withdrawable, marginUsed float64
+	var positionsJSON string
+	err := db.QueryRow("SELECT account_value, withdrawable, margin_used, positions FROM subscription_states WHERE chat_id = ? AND address = ?", chatID, address).
+		Scan(&accountValue, &withdrawable, &marginUsed, &positionsJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make(map[string]hyperliquid.Position)
+	if positionsJSON != "" {
+		if err := json.Unmarshal([]byte(positionsJSON), &positions); err != nil {
+			return nil, err
+		}
+	}
+	return &AccountState{
+		LastPositions:    positions,
+		LastAccountValue: accountValue,
+		LastWithdrawable: withdrawable,
+		LastMarginUsed:   marginUsed,
+	}, nil
+}
+
+func saveSubscriptionStateToDB(wallet WalletConfig, state *AccountState) error {
+	positionsJSON, err := json.Marshal(state.LastPositions)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+        INSERT OR REPLACE INTO subscription_states (chat_id, address, account_value, withdrawable, margin_used, positions)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `, wallet.ChatID, wallet.Address, state.LastAccountValue, state.LastWithdrawable, state.LastMarginUsed, string(positionsJSON))
 	return err
 }
 
@@ -438,13 +845,13 @@ func subscribeWallet(chatID, address, name string) {
 	// 如果是第一个订阅该地址的用户，初始化状态
 	if _, exists := accountStates[address]; !exists {
 		accountStates[address] = &AccountState{
-			LastPositions:    make(map[string]Position),
+			LastPositions:    make(map[string]hyperliquid.Position),
 			LastAccountValue: 0,
 		}
 	}
 
 	go func() {
-		currentPositions, currentAccountValue, err := fetchPositions(address)
+		currentPositions, summary, err := fetchPositions(address)
 		if err != nil {
 			log.Printf("首次获取 %s 持仓失败: %v", address, err)
 			sendMessage(chatID, fmt.Sprintf("获取地址 %s 初始状态失败: %v", shortenAddress(address), err))
@@ -452,17 +859,34 @@ func subscribeWallet(chatID, address, name string) {
 		}
 
 		// 发送初始状态给新订阅用户
-		message := generateInitialStatusMessage(wallet, currentPositions, currentAccountValue)
-		err = sendMessage(chatID, message)
-		if err != nil {
-			log.Printf("发送初始状态失败 %s: %v", address, err)
+		message, _ := textRenderer.RenderInitialStatus(wallet, currentPositions, summary, time.Now())
+		sendMessage(chatID, message)
+
+		monitorMutex.Lock()
+		defer monitorMutex.Unlock()
+
+		// 新订阅从当前状态开始比较
+		subscriptionState := &AccountState{LastPositions: currentPositions}
+		subscriptionState.setSummary(summary)
+		if storeSubscriptionState(wallet, subscriptionState) {
+			if err := saveSubscriptionStateToDB(wallet, subscriptionState); err != nil {
+				log.Printf("保存订阅状态失败 %s: %v", address, err)
+			}
 		}
 
 		// 如果是第一个订阅者，更新状态
-		if len(wallets) == 1 || !hasSubscribers(address, chatID) {
-			accountStates[address].LastPositions = currentPositions
-			accountStates[address].LastAccountValue = currentAccountValue
-			if err := saveAccountStateToDB(address, accountStates[address]); err != nil {
+		walletMutex.Lock()
+		current, exists := accountStates[address]
+		firstSubscriber := exists && !hasSubscribers(address, chatID)
+		walletMutex.Unlock()
+		if !firstSubscriber {
+			return
+		}
+		next := *current
+		next.LastPositions = currentPositions
+		next.setSummary(summary)
+		if storeAccountState(address, &next) {
+			if err := saveAccountStateToDB(address, &next); err != nil {
 				log.Printf("保存账户状态失败 %s: %v", address, err)
 			}
 		}
@@ -496,6 +920,10 @@ func unsubscribeWallet(chatID, address string) {
 	if err := deleteSubscriptionFromDB(chatID, address); err != nil {
 		log.Printf("从数据库删除订阅失败: %v", err)
 	}
+	delete(subscriptionStates, key)
+	if _, err := db.Exec("DELETE FROM subscription_states WHERE chat_id = ? AND address = ?", chatID, address); err != nil {
+		log.Printf("删除订阅状态失败 %s: %v", address, err)
+	}
 
 	// 如果没有其他订阅者，清理状态
 	if !hasSubscribers(address, "") {
@@ -504,249 +932,201 @@ func unsubscribeWallet(chatID, address string) {
 		if err != nil {
 			log.Printf("删除账户状态失败 %s: %v", address, err)
 		}
+		if err := deleteFillCursor(address); err != nil {
+			log.Printf("删除成交游标失败 %s: %v", address, err)
+		}
+		clearLiquidationState(address)
 	}
 
 	sendMessage(chatID, fmt.Sprintf("已取消订阅地址 %s", shortenAddress(address)))
 }
 
-func listSubscriptions(chatID string) {
-	walletMutex.Lock()
-	defer walletMutex.Unlock()
-
-	message := "📋 您的订阅列表:\n\n"
-	count := 0
-	for key, wallet := range wallets {
-		if strings.HasPrefix(key, chatID+"_") {
-			count++
-			message += fmt.Sprintf("%d. %s - %s\n", count, wallet.Address, wallet.Name)
+func monitorAllWallets() {
+	// 对每个地址只获取一次数据
+	for address, subscribers := range subscribersByAddress() {
+		checkAddress(address, subscribers)
+		if config.NotifyFills {
+			checkFills(address, subscribers)
+		}
+		if config.TrackOrders {
+			checkOrders(address, subscribers)
 		}
 	}
-	if count == 0 {
-		message = "您尚未订阅任何地址。"
-	}
-	sendMessage(chatID, message)
 }
 
-func monitorAllWallets() {
+// 按地址聚合订阅者
+func subscribersByAddress() map[string][]WalletConfig {
 	walletMutex.Lock()
-	walletsCopy := make(map[string]WalletConfig)
-	for k, v := range wallets {
-		walletsCopy[k] = v
-	}
-	walletMutex.Unlock()
+	defer walletMutex.Unlock()
 
-	// 按地址聚合订阅者
 	addressSubscribers := make(map[string][]WalletConfig)
-	for _, wallet := range walletsCopy {
+	for _, wallet := range wallets {
 		addressSubscribers[wallet.Address] = append(addressSubscribers[wallet.Address], wallet)
 	}
+	return addressSubscribers
+}
 
-	// 对每个地址只获取一次数据
-	for address, subscribers := range addressSubscribers {
-		currentPositions, currentAccountValue, err := fetchPositions(address)
-		if err != nil {
-			log.Printf("监控 %s 失败: %v", address, err)
-			continue
-		}
-
-		state, exists := accountStates[address]
-		if !exists {
-			// 如果状态不存在，可能是新地址，直接初始化并通知所有订阅者
-			state = &AccountState{
-				LastPositions:    make(map[string]Position),
-				LastAccountValue: 0,
-			}
-			accountStates[address] = state
-		}
+func getOrCreateAccountState(address string) *AccountState {
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
 
-		changes := detectPositionChanges(subscribers[0], currentPositions, currentAccountValue, state)
-		if changes != "" {
-			// 通知所有订阅该地址的用户
-			for _, wallet := range subscribers {
-				changes = detectPositionChanges(wallet, currentPositions, currentAccountValue, state)
-				err = sendMessage(wallet.ChatID, changes)
-				if err != nil {
-					log.Printf("发送变化通知失败 %s (ChatID: %s): %v", address, wallet.ChatID, err)
-				}
-			}
-			// 更新状态
-			state.LastPositions = currentPositions
-			state.LastAccountValue = currentAccountValue
-			if err := saveAccountStateToDB(address, state); err != nil {
-				log.Printf("保存账户状态失败 %s: %v", address, err)
-			}
+	state, exists := accountStates[address]
+	if !exists {
+		// 如果状态不存在，可能是新地址，直接初始化并通知所有订阅者
+		state = &AccountState{
+			LastPositions:    make(map[string]hyperliquid.Position),
+			LastAccountValue: 0,
 		}
+		accountStates[address] = state
 	}
+	return state
 }
 
-func sendMessage(chatID, message string) error {
-	msg := tgbotapi.NewMessageToChannel(chatID, message)
-	_, err := bot.Send(msg)
-	return err
+// 待发送的提醒，在释放 monitorMutex 之后发送
+type pendingNotification struct {
+	wallet       WalletConfig
+	notification Notification
 }
 
-func generateInitialStatusMessage(wallet WalletConfig, positions map[string]Position, accountValue float64) string {
-	timeStamp := time.Now().Format("2006-01-02 15:04:05")
-	message := fmt.Sprintf("🔄 HyperLiquid初始持仓状态 - %s (%s)\n\n", wallet.Name, timeStamp)
-	message += fmt.Sprintf("💼 账户地址: %s\n", shortenAddress(wallet.Address))
-	message += fmt.Sprintf("💰 账户价值: $%.2f\n\n", accountValue)
-
-	if len(positions) > 0 {
-		message += "📊 当前持仓:\n\n"
-		for _, position := range positions {
-			szi, _ := strconv.ParseFloat(position.Szi, 64)
-			entryPx, _ := strconv.ParseFloat(position.EntryPx, 64)
-			posValue, _ := strconv.ParseFloat(position.PositionValue, 64)
-			unrealizedPnl, _ := strconv.ParseFloat(position.UnrealizedPnl, 64)
-			roi, _ := strconv.ParseFloat(position.ReturnOnEquity, 64)
-			liquidationPx, _ := strconv.ParseFloat(position.LiquidationPx, 64)
-			marginUsed, _ := strconv.ParseFloat(position.MarginUsed, 64)
-
-			direction := "多头"
-			if szi < 0 {
-				direction = "空头"
-				szi = -szi
-			}
+// 对比最新状态并通知订阅者，轮询和 WebSocket 共用
+func processAccountUpdate(address string, subscribers []WalletConfig, currentPositions map[string]hyperliquid.Position, summary AccountSummary) {
+	if len(subscribers) == 0 {
+		return
+	}
 
-			message += fmt.Sprintf("🪙 %s (%s)\n", position.Coin, direction)
-			message += fmt.Sprintf("📈 仓位大小: %.5f ($%.2f)\n", szi, posValue)
-			message += fmt.Sprintf("🏷️ 入场价格: $%.2f\n", entryPx)
-			message += fmt.Sprintf("📊 杠杆: %dx (%s)\n", position.Leverage.Value, position.Leverage.Type)
-			pnlEmoji := "🔴"
-			if unrealizedPnl >= 0 {
-				pnlEmoji = "🟢"
-			}
-			message += fmt.Sprintf("%s 盈亏: $%.2f (%.2f%%)\n", pnlEmoji, unrealizedPnl, roi*100)
-			message += fmt.Sprintf("⚠️ 强平价格: $%.2f\n", liquidationPx)
-			message += fmt.Sprintf("💸 已用保证金: $%.2f\n\n", marginUsed)
+	// 部分渠道同步发送，遇到超时会阻塞，在释放 monitorMutex 之后发送，避免阻塞其他地址的更新
+	for _, pending := range detectAccountChanges(address, subscribers, currentPositions, summary) {
+		if err := notifyWallet(pending.wallet, pending.notification); err != nil {
+			log.Printf("发送变化通知失败 %s (ChatID: %s): %v", address, pending.wallet.ChatID, err)
 		}
-	} else {
-		message += "没有找到开放的持仓。\n"
 	}
-	message += "🔔 持仓监控已启动，将在仓位变化时发送通知。"
-	return message
 }
 
-func fetchPositions(address string) (map[string]Position, float64, error) {
-	requestData := ClearinghouseRequest{
-		Type: "clearinghouseState",
-		User: address,
-	}
+// 更新地址和各订阅的状态，返回需要发送的变化通知
+func detectAccountChanges(address string, subscribers []WalletConfig, currentPositions map[string]hyperliquid.Position, summary AccountSummary) []pendingNotification {
+	monitorMutex.Lock()
+	defer monitorMutex.Unlock()
 
-	jsonData, err := json.Marshal(requestData)
-	if err != nil {
-		return nil, 0, fmt.Errorf("转换JSON时出错: %v", err)
-	}
+	recordAccountSnapshot(address, currentPositions, summary, time.Now())
+	state := getOrCreateAccountState(address)
 
-	req, err := http.NewRequest("POST", ApiEndpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, 0, fmt.Errorf("创建请求时出错: %v", err)
+	// 先取出各订阅的基线（没有订阅级基线时沿用旧的地址状态），再更新地址状态，
+	// 这样通知渲染时（例如邮件中的账户概览）看到的是最新快照
+	baselines := make([]*AccountState, len(subscribers))
+	for i, wallet := range subscribers {
+		baselines[i] = getOrCreateSubscriptionState(wallet)
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, 0, fmt.Errorf("发送请求时出错: %v", err)
+	next := *state
+	next.LastPositions = currentPositions
+	next.setSummary(summary)
+	stored := storeAccountState(address, &next)
+
+	// 每个订阅按自己的阈值和基线检测
+	var pending []pendingNotification
+	for i, wallet := range subscribers {
+		baseline := baselines[i]
+		changes := newDetector(wallet).Detect(baseline, currentPositions, summary, time.Now())
+		if changes.Empty() {
+			continue
+		}
+		message, _ := textRenderer.RenderChanges(wallet, changes)
+		pending = append(pending, pendingNotification{wallet: wallet, notification: Notification{
+			Title: "HyperLiquid持仓变化", Text: message, Changes: &changes, Priority: changes.Priority(),
+		}})
+		updated := *baseline
+		updated.LastPositions = currentPositions
+		updated.setSummary(summary)
+		if !storeSubscriptionState(wallet, &updated) {
+			continue
+		}
+		if err := saveSubscriptionStateToDB(wallet, &updated); err != nil {
+			log.Printf("保存订阅状态失败 %s (ChatID: %s): %v", address, wallet.ChatID, err)
+		}
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, 0, fmt.Errorf("读取响应时出错: %v", err)
+	if len(pending) > 0 && stored {
+		if err := saveAccountStateToDB(address, &next); err != nil {
+			log.Printf("保存账户状态失败 %s: %v", address, err)
+		}
 	}
+	return pending
+}
 
-	var responseData Response
-	if err := json.Unmarshal(body, &responseData); err != nil {
-		return nil, 0, fmt.Errorf("解析响应时出错: %v", err)
-	}
+// 替换地址状态，地址已没有订阅者（状态已被清理）时不再保存
+func storeAccountState(address string, state *AccountState) bool {
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
 
-	accountValue, _ := strconv.ParseFloat(responseData.MarginSummary.AccountValue, 64)
-	positions := make(map[string]Position)
-	for _, pos := range responseData.AssetPositions {
-		positions[pos.Position.Coin] = pos.Position
+	if _, exists := accountStates[address]; !exists {
+		return false
 	}
-
-	return positions, accountValue, nil
+	accountStates[address] = state
+	return true
 }
 
-func detectPositionChanges(wallet WalletConfig, currentPositions map[string]Position, currentAccountValue float64, state *AccountState) string {
-	changes := ""
-	timeStamp := time.Now().Format("2006-01-02 15:04:05")
-
-	for coin, current := range currentPositions {
-		last, exists := state.LastPositions[coin]
-		if !exists {
-			if changes == "" {
-				changes = fmt.Sprintf("🔄 HyperLiquid持仓变化 - %s (%s)\n\n", wallet.Name, timeStamp)
-				changes += fmt.Sprintf("💼 账户地址: %s\n\n", shortenAddress(wallet.Address))
-			}
-			changes += fmt.Sprintf("🆕 新开仓位: %s\n", coin)
-			addPositionDetails(&changes, current)
-			continue
-		}
+// 替换订阅的通知基线，订阅已被取消时不再保存
+func storeSubscriptionState(wallet WalletConfig, state *AccountState) bool {
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
 
-		currentSzi, _ := strconv.ParseFloat(current.Szi, 64)
-		lastSzi, _ := strconv.ParseFloat(last.Szi, 64)
-		sziChangePercent := 0.0
-		if lastSzi != 0 {
-			sziChangePercent = math.Abs((currentSzi-lastSzi)/lastSzi) * 100
-		}
+	key := wallet.ChatID + "_" + wallet.Address
+	if _, exists := wallets[key]; !exists {
+		return false
+	}
+	subscriptionStates[key] = state
+	return true
+}
 
-		if sziChangePercent >= 1.0 {
-			if changes == "" {
-				changes = fmt.Sprintf("🔄 HyperLiquid持仓变化 - %s (%s)\n\n", wallet.Name, timeStamp)
-				changes += fmt.Sprintf("💼 账户地址: %s\n\n", shortenAddress(wallet.Address))
-			}
+// 旧版本没有订阅级基线时，沿用地址状态作为起点
+func getOrCreateSubscriptionState(wallet WalletConfig) *AccountState {
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
 
-			if math.Abs(currentSzi) > math.Abs(lastSzi) {
-				changes += fmt.Sprintf("📈 仓位增加: %s\n", coin)
-			} else {
-				changes += fmt.Sprintf("📉 仓位减少: %s\n", coin)
-			}
-			changes += fmt.Sprintf("   从: %.5f\n", lastSzi)
-			changes += fmt.Sprintf("   到: %.5f\n", currentSzi)
-			changes += fmt.Sprintf("   变化: %.2f%%\n\n", sziChangePercent)
-		}
+	key := wallet.ChatID + "_" + wallet.Address
+	state, exists := subscriptionStates[key]
+	if exists {
+		return state
 	}
-
-	for coin := range state.LastPositions {
-		if _, exists := currentPositions[coin]; !exists {
-			if changes == "" {
-				changes = fmt.Sprintf("🔄 HyperLiquid持仓变化 - %s (%s)\n\n", wallet.Name, timeStamp)
-				changes += fmt.Sprintf("💼 账户地址: %s\n\n", shortenAddress(wallet.Address))
-			}
-			changes += fmt.Sprintf("❌ 已关闭仓位: %s\n\n", coin)
-		}
+	state = &AccountState{LastPositions: make(map[string]hyperliquid.Position)}
+	if addressState, exists := accountStates[wallet.Address]; exists {
+		state.LastPositions = addressState.LastPositions
+		state.LastAccountValue = addressState.LastAccountValue
+		state.LastWithdrawable = addressState.LastWithdrawable
+		state.LastMarginUsed = addressState.LastMarginUsed
 	}
-
-	return changes
+	subscriptionStates[key] = state
+	return state
 }
 
-func addPositionDetails(message *string, position Position) {
-	szi, _ := strconv.ParseFloat(position.Szi, 64)
-	entryPx, _ := strconv.ParseFloat(position.EntryPx, 64)
-	posValue, _ := strconv.ParseFloat(position.PositionValue, 64)
-	unrealizedPnl, _ := strconv.ParseFloat(position.UnrealizedPnl, 64)
-	roi, _ := strconv.ParseFloat(position.ReturnOnEquity, 64)
-	liquidationPx, _ := strconv.ParseFloat(position.LiquidationPx, 64)
+func fetchPositions(address string) (map[string]hyperliquid.Position, AccountSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
 
-	direction := "多头"
-	if szi < 0 {
-		direction = "空头"
-		szi = -szi
+	state, err := hlClient.ClearinghouseState(ctx, address)
+	if err != nil {
+		return nil, AccountSummary{}, err
 	}
 
-	*message += fmt.Sprintf("   %s (%s)\n", position.Coin, direction)
-	*message += fmt.Sprintf("   📈 仓位大小: %.5f ($%.2f)\n", szi, posValue)
-	*message += fmt.Sprintf("   🏷️ 入场价格: $%.2f\n", entryPx)
-	*message += fmt.Sprintf("   📊 杠杆: %dx\n", position.Leverage.Value)
-	pnlEmoji := "🔴"
-	if unrealizedPnl >= 0 {
-		pnlEmoji = "🟢"
+	positions, summary := parseClearinghouseState(state)
+	return positions, summary, nil
+}
+
+func parseClearinghouseState(state *hyperliquid.ClearinghouseState) (map[string]hyperliquid.Position, AccountSummary) {
+	var summary AccountSummary
+	summary.AccountValue, _ = strconv.ParseFloat(state.MarginSummary.AccountValue, 64)
+	summary.Withdrawable, _ = strconv.ParseFloat(state.Withdrawable, 64)
+	summary.TotalMarginUsed, _ = strconv.ParseFloat(state.MarginSummary.TotalMarginUsed, 64)
+	positions := make(map[string]hyperliquid.Position)
+	for _, pos := range state.AssetPositions {
+		positions[pos.Position.Coin] = pos.Position
 	}
-	*message += fmt.Sprintf("   %s 盈亏: $%.2f (%.2f%%)\n", pnlEmoji, unrealizedPnl, roi*100)
-	*message += fmt.Sprintf("   ⚠️ 强平价格: $%.2f\n\n", liquidationPx)
+	return positions, summary
+}
+
+func (state *AccountState) setSummary(summary AccountSummary) {
+	state.LastAccountValue = summary.AccountValue
+	state.LastWithdrawable = summary.Withdrawable
+	state.LastMarginUsed = summary.TotalMarginUsed
 }
 
 func shortenAddress(address string) string {
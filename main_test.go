@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+// setupTestDB 为测试打开临时数据库并重置全局状态，测试结束后恢复
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	testDB, err := initDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("initDB: %v", err)
+	}
+
+	oldDB, oldConfig := db, config
+	db = testDB
+	config = &Config{PollingInterval: 30}
+
+	walletMutex.Lock()
+	wallets = make(map[string]WalletConfig)
+	accountStates = make(map[string]*AccountState)
+	subscriptionStates = make(map[string]*AccountState)
+	walletMutex.Unlock()
+
+	t.Cleanup(func() {
+		testDB.Close()
+		db, config = oldDB, oldConfig
+	})
+}
+
+func setAccountState(address string, positions map[string]hyperliquid.Position, summary AccountSummary) {
+	state := &AccountState{LastPositions: positions}
+	state.setSummary(summary)
+	walletMutex.Lock()
+	accountStates[address] = state
+	walletMutex.Unlock()
+}
+
+// blockingNotifier 在 release 关闭前阻塞，模拟无响应的通知渠道
+type blockingNotifier struct {
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (n *blockingNotifier) Notify(target string, notification Notification) error {
+	close(n.called)
+	<-n.release
+	return nil
+}
+
+// 发送变化通知时不应持有 monitorMutex，否则一个慢渠道会阻塞所有地址的监控
+func TestAccountNotificationsSentAfterMonitorMutex(t *testing.T) {
+	setupTestDB(t)
+	notifier := &blockingNotifier{called: make(chan struct{}), release: make(chan struct{})}
+	registerNotifier(testChannel, notifier)
+	t.Cleanup(func() { delete(notifiers, testChannel) })
+
+	address := "0x7777777777777777777777777777777777777777"
+	wallet := WalletConfig{Address: address, Name: "测试", ChatID: "1", Channel: testChannel}
+	walletMutex.Lock()
+	wallets[wallet.ChatID+"_"+address] = wallet
+	walletMutex.Unlock()
+	setAccountState(address, map[string]hyperliquid.Position{}, AccountSummary{AccountValue: 1000})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		processAccountUpdate(address, []WalletConfig{wallet}, map[string]hyperliquid.Position{
+			"BTC": {Coin: "BTC", Szi: "1", PositionValue: "100", Leverage: hyperliquid.Leverage{Type: "cross", Value: 5}},
+		}, AccountSummary{AccountValue: 1000})
+	}()
+	<-notifier.called
+
+	locked := make(chan struct{})
+	go func() {
+		monitorMutex.Lock()
+		monitorMutex.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		close(notifier.release)
+		t.Fatal("monitorMutex held while sending notifications")
+	}
+	close(notifier.release)
+	<-done
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ChannelMatrix = "matrix"
+
+	matrixMaxAttempts = 3
+	matrixRetryDelay  = time.Second
+)
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+// MatrixNotifier 通过 Client-Server API 向房间发送消息，target 为房间的 API 地址
+// （见 matrixRoomURL），订阅的 Secret 为机器人账号的 Access Token
+//
+// 低优先级以 m.notice 发送，按默认推送规则不会响铃；紧急提醒附带 @room 提及全体成员。
+// 消息在后台发送，网络错误、5xx 和 429 会沿用同一事务ID重试。
+type MatrixNotifier struct {
+	HTTPClient *http.Client
+}
+
+func NewMatrixNotifier() *MatrixNotifier {
+	return &MatrixNotifier{HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (m *MatrixNotifier) Notify(target string, notification Notification) error {
+	message := matrixMessage{
+		MsgType:       "m.text",
+		Body:          notification.Text,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: matrixHTML(notification),
+	}
+	switch notification.Priority {
+	case PriorityLow:
+		message.MsgType = "m.notice"
+	case PriorityUrgent:
+		message.Body = "@room " + message.Body
+		message.FormattedBody = "@room " + message.FormattedBody
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("转换JSON时出错: %v", err)
+	}
+	// 每条提醒只生成一次事务ID，重试时沿用，服务器据此去重
+	endpoint := target + "/send/m.room.message/" + newDeliveryID()
+	go m.deliver(endpoint, notification.Wallet.Secret, data, notification.sent)
+	return nil
+}
+
+func (MatrixNotifier) sendsInBackground() {}
+
+// 在后台发送并重试，不阻塞调用方
+func (m *MatrixNotifier) deliver(endpoint, accessToken string, data []byte, delivered func()) {
+	delay := matrixRetryDelay
+	for attempt := 1; ; attempt++ {
+		retryable, err := m.put(endpoint, accessToken, data)
+		if err == nil {
+			delivered()
+			return
+		}
+		if !retryable || attempt == matrixMaxAttempts {
+			log.Printf("发送Matrix消息失败，已放弃: %v", err)
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// 返回值表示失败后是否值得重试
+func (m *MatrixNotifier) put(endpoint, accessToken string, data []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return false, fmt.Errorf("创建Matrix请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := m.HTTPClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("发送Matrix消息失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retryable, fmt.Errorf("Matrix返回状态码 %d: %s", resp.StatusCode, string(body))
+	}
+	return false, nil
+}
+
+func matrixHTML(notification Notification) string {
+	wallet := notification.Wallet
+	var builder strings.Builder
+	if notification.Title != "" {
+		fmt.Fprintf(&builder, "<h4>%s</h4>", html.EscapeString(notification.Title))
+	}
+	fmt.Fprintf(&builder, "<p>账户: %s (<code>%s</code>)</p>", html.EscapeString(wallet.Name), shortenAddress(wallet.Address))
+	for _, section := range markdownSections(notification) {
+		fmt.Fprintf(&builder, "<p><b>%s</b>", html.EscapeString(section.Title))
+		for _, line := range section.Lines {
+			builder.WriteString("<br>" + html.EscapeString(line))
+		}
+		builder.WriteString("</p>")
+	}
+	return builder.String()
+}
+
+// 房间的 API 地址，发送时在后面拼接 /send/m.room.message/<事务ID>
+func matrixRoomURL(homeserver, roomID string) string {
+	return strings.TrimRight(homeserver, "/") + "/_matrix/client/v3/rooms/" + url.PathEscape(roomID)
+}
+
+// 只接受 !开头的房间ID，房间别名需要额外解析
+func isValidMatrixRoomID(roomID string) bool {
+	return strings.HasPrefix(roomID, "!") && strings.Contains(roomID, ":")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 重试时沿用同一个事务ID，服务器才能识别出重复请求
+func TestMatrixRetryReusesTxnID(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	delivered := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("request = %s %s, auth %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		if len(paths) == 1 {
+			http.Error(w, "unavailable", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"event_id":"$1"}`))
+		close(delivered)
+	}))
+	defer server.Close()
+
+	target := matrixRoomURL(server.URL, "!room:example.com")
+	wallet := WalletConfig{Address: "0x9999999999999999999999999999999999999999", Name: "测试", Secret: "token"}
+	if err := NewMatrixNotifier().Notify(target, Notification{Wallet: wallet, Text: "提醒"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	// 重试在后台进行，Notify 不等待发送结果
+	select {
+	case <-delivered:
+	case <-time.After(10 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) != 2 {
+		t.Fatalf("got %d requests, want 2", len(paths))
+	}
+	if paths[0] != paths[1] || !strings.Contains(paths[0], "/send/m.room.message/") {
+		t.Errorf("retry used a different transaction: %q then %q", paths[0], paths[1])
+	}
+}
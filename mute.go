@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // 精简的容器镜像可能没有时区数据
+)
+
+const defaultTimezone = "Asia/Shanghai"
+
+// QuietHours 是某个聊天的静默时段，Start 和 End 为一天中的第几分钟，Start > End 表示跨越午夜
+type QuietHours struct {
+	Start    int
+	End      int
+	Location *time.Location
+}
+
+var (
+	quietHours = make(map[string]QuietHours)
+	quietMutex sync.Mutex
+)
+
+func (q QuietHours) active(now time.Time) bool {
+	local := now.In(q.Location)
+	minute := local.Hour()*60 + local.Minute()
+	if q.Start <= q.End {
+		return minute >= q.Start && minute < q.End
+	}
+	return minute >= q.Start || minute < q.End
+}
+
+func (q QuietHours) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d (%s)", q.Start/60, q.Start%60, q.End/60, q.End%60, q.Location)
+}
+
+func inQuietHours(chatID string, now time.Time) bool {
+	quietMutex.Lock()
+	defer quietMutex.Unlock()
+	q, exists := quietHours[chatID]
+	return exists && q.active(now)
+}
+
+// "HH:MM" 转为一天中的第几分钟
+func parseClock(clock string) (int, bool) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// 支持 time.ParseDuration 的格式以及按天的 "1d"
+func parseMuteDuration(text string) (time.Duration, bool) {
+	if days, found := strings.CutSuffix(text, "d"); found {
+		var n int
+		if _, err := fmt.Sscanf(days, "%d", &n); err != nil || n <= 0 || fmt.Sprint(n) != days {
+			return 0, false
+		}
+		return time.Duration(n) * 24 * time.Hour, true
+	}
+	duration, err := time.ParseDuration(text)
+	if err != nil || duration <= 0 {
+		return 0, false
+	}
+	return duration, true
+}
+
+// 永久静音，或仍在暂停提醒的时限内
+func (w WalletConfig) isMuted(now time.Time) bool {
+	return w.Muted || now.Before(w.MutedUntil)
+}
+
+// muted 为 true 表示永久静音；否则 until 不为零时暂停提醒到该时间，两者都为零值时取消静音
+func setWalletMute(chatID, address string, muted bool, until time.Time) (WalletConfig, bool) {
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+
+	key := chatID + "_" + address
+	wallet, exists := wallets[key]
+	if !exists {
+		return wallet, false
+	}
+	var untilUnix int64
+	if !until.IsZero() {
+		untilUnix = until.Unix()
+	}
+	if _, err := db.Exec("UPDATE subscriptions SET muted = ?, muted_until = ? WHERE chat_id = ? AND address = ?", muted, untilUnix, chatID, address); err != nil {
+		log.Printf("保存静音状态失败: %v", err)
+		return wallet, false
+	}
+	wallet.Muted = muted
+	wallet.MutedUntil = until
+	wallets[key] = wallet
+	return wallet, true
+}
+
+// /mute <地址|名称|#标签> [时长] 和 /unmute <地址|名称|#标签>
+func handleMuteCommand(chatID, msgText string, mute bool) {
+	parts := strings.Fields(msgText)
+	if len(parts) < 2 {
+		if mute {
+			sendMessage(chatID, "用法: /mute <地址|名称|#标签> [时长]\n时长如 30m、2h、1d，不填则一直静音直到 /unmute。")
+		} else {
+			sendMessage(chatID, "用法: /unmute <地址|名称|#标签>")
+		}
+		return
+	}
+
+	var until time.Time
+	selectorParts := parts[1:]
+	if mute && len(selectorParts) > 1 {
+		if duration, ok := parseMuteDuration(selectorParts[len(selectorParts)-1]); ok {
+			until = time.Now().Add(duration)
+			selectorParts = selectorParts[:len(selectorParts)-1]
+		}
+	}
+	selector := strings.Join(selectorParts, " ")
+
+	walletMutex.Lock()
+	matched := matchWallets(chatID, selector)
+	walletMutex.Unlock()
+	if len(matched) == 0 {
+		sendMessage(chatID, fmt.Sprintf("未找到匹配 %s 的订阅", selector))
+		return
+	}
+	for _, wallet := range matched {
+		if _, ok := setWalletMute(chatID, wallet.Address, mute && until.IsZero(), until); !ok {
+			sendMessage(chatID, "保存静音状态失败，请稍后重试。")
+			return
+		}
+	}
+	switch {
+	case !mute:
+		sendMessage(chatID, fmt.Sprintf("已取消静音 %d 个订阅", len(matched)))
+	case until.IsZero():
+		sendMessage(chatID, fmt.Sprintf("已静音 %d 个订阅", len(matched)))
+	default:
+		sendMessage(chatID, fmt.Sprintf("已静音 %d 个订阅，至 %s 自动恢复", len(matched), until.Format("2006-01-02 15:04")))
+	}
+}
+
+func loadQuietHoursFromDB() error {
+	rows, err := db.Query("SELECT chat_id, start_minute, end_minute, timezone FROM quiet_hours")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	quietMutex.Lock()
+	defer quietMutex.Unlock()
+	for rows.Next() {
+		var chatID, timezone string
+		var q QuietHours
+		if err := rows.Scan(&chatID, &q.Start, &q.End, &timezone); err != nil {
+			return err
+		}
+		if q.Location, err = time.LoadLocation(timezone); err != nil {
+			log.Printf("无效的时区 %s (ChatID: %s): %v", timezone, chatID, err)
+			continue
+		}
+		quietHours[chatID] = q
+	}
+	return rows.Err()
+}
+
+func handleQuietCommand(chatID, msgText string) {
+	parts := strings.Fields(msgText)
+	usage := "用法:\n/quiet - 查看静默时段\n/quiet 23:00-07:00 [时区] - 设置静默时段，默认时区 " + defaultTimezone + "\n/quiet off - 关闭静默时段\n\n静默时段内的提醒会暂存，结束后合并为一条汇总发送；强平等紧急提醒不受影响。"
+
+	switch {
+	case len(parts) == 1:
+		quietMutex.Lock()
+		q, exists := quietHours[chatID]
+		quietMutex.Unlock()
+		if !exists {
+			sendMessage(chatID, "当前未设置静默时段。\n\n"+usage)
+			return
+		}
+		sendMessage(chatID, fmt.Sprintf("🌙 静默时段: %s", q))
+
+	case len(parts) == 2 && parts[1] == "off":
+		if _, err := db.Exec("DELETE FROM quiet_hours WHERE chat_id = ?", chatID); err != nil {
+			log.Printf("删除静默时段失败: %v", err)
+			sendMessage(chatID, "保存失败，请稍后重试。")
+			return
+		}
+		quietMutex.Lock()
+		delete(quietHours, chatID)
+		quietMutex.Unlock()
+		sendMessage(chatID, "已关闭静默时段，暂存的提醒将很快发送。")
+
+	case len(parts) == 2 || len(parts) == 3:
+		startText, endText, found := strings.Cut(parts[1], "-")
+		start, okStart := parseClock(startText)
+		end, okEnd := parseClock(endText)
+		if !found || !okStart || !okEnd || start == end {
+			sendMessage(chatID, usage)
+			return
+		}
+		timezone := defaultTimezone
+		if len(parts) == 3 {
+			timezone = parts[2]
+		}
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			sendMessage(chatID, fmt.Sprintf("无效的时区: %s，请使用如 Asia/Shanghai、UTC 的时区名称。", timezone))
+			return
+		}
+		_, err = db.Exec(`
+            INSERT INTO quiet_hours (chat_id, start_minute, end_minute, timezone) VALUES (?, ?, ?, ?)
+            ON CONFLICT(chat_id) DO UPDATE SET start_minute = excluded.start_minute, end_minute = excluded.end_minute, timezone = excluded.timezone
+        `, chatID, start, end, timezone)
+		if err != nil {
+			log.Printf("保存静默时段失败: %v", err)
+			sendMessage(chatID, "保存失败，请稍后重试。")
+			return
+		}
+		q := QuietHours{Start: start, End: end, Location: location}
+		quietMutex.Lock()
+		quietHours[chatID] = q
+		quietMutex.Unlock()
+		sendMessage(chatID, fmt.Sprintf("🌙 已设置静默时段: %s", q))
+
+	default:
+		sendMessage(chatID, usage)
+	}
+}
+
+func queueQuietNotification(wallet WalletConfig, notification Notification) error {
+	_, err := db.Exec(`
+        INSERT INTO quiet_queue (chat_id, address, title, text, created_at) VALUES (?, ?, ?, ?, ?)
+    `, wallet.ChatID, wallet.Address, notification.Title, notification.Text, time.Now().Unix())
+	return err
+}
+
+// 每分钟检查一次，把已结束静默时段的聊天暂存的提醒合并发送
+func runQuietHoursFlusher() {
+	for {
+		flushQuietQueue(time.Now())
+		time.Sleep(time.Minute)
+	}
+}
+
+func flushQuietQueue(now time.Time) {
+	rows, err := db.Query("SELECT id, chat_id, address, title, text, created_at FROM quiet_queue ORDER BY id")
+	if err != nil {
+		log.Printf("读取静默队列失败: %v", err)
+		return
+	}
+
+	// 同一聊天中发往同一目的地的提醒合并为一条
+	type queued struct {
+		ids       []int64
+		wallets   []WalletConfig
+		addresses map[string]bool
+		texts     []string
+	}
+	groups := make(map[string]*queued)
+	var keys []string
+	var dropped []int64
+
+	walletMutex.Lock()
+	for rows.Next() {
+		var id, createdAt int64
+		var chatID, address, title, text string
+		if err := rows.Scan(&id, &chatID, &address, &title, &text, &createdAt); err != nil {
+			log.Printf("读取静默队列失败: %v", err)
+			break
+		}
+		if inQuietHours(chatID, now) {
+			continue
+		}
+		// 期间取消订阅或静音的地址不再补发
+		wallet, exists := wallets[chatID+"_"+address]
+		if !exists || wallet.isMuted(now) {
+			dropped = append(dropped, id)
+			continue
+		}
+		channel, target := wallet.destination()
+		key := chatID + "|" + channel + "|" + target
+		q, exists := groups[key]
+		if !exists {
+			q = &queued{addresses: make(map[string]bool)}
+			groups[key] = q
+			keys = append(keys, key)
+		}
+		q.ids = append(q.ids, id)
+		if !q.addresses[strings.ToLower(wallet.Address)] {
+			q.addresses[strings.ToLower(wallet.Address)] = true
+			q.wallets = append(q.wallets, wallet)
+		}
+		q.texts = append(q.texts, fmt.Sprintf("[%s] %s", time.Unix(createdAt, 0).Format("15:04"), text))
+	}
+	walletMutex.Unlock()
+	rows.Close()
+
+	deleteQuietQueueItems(dropped)
+	for _, key := range keys {
+		q := groups[key]
+		wallet := q.wallets[0]
+		if len(q.wallets) > 1 {
+			wallet.Name = fmt.Sprintf("%d个账户", len(q.wallets))
+		}
+		channel, target := wallet.destination()
+		notifier, exists := notifiers[channel]
+		if !exists {
+			log.Printf("未知的通知渠道: %s", channel)
+			continue
+		}
+
+		text := fmt.Sprintf("🌙 静默时段汇总 - 共 %d 条提醒\n\n", len(q.texts)) + strings.Join(q.texts, "\n———\n\n")
+		err := notifier.Notify(target, Notification{Wallet: wallet, Title: "HyperLiquid静默时段汇总", Text: text})
+		if err != nil {
+			log.Printf("发送静默时段汇总失败 (ChatID: %s): %v", wallet.ChatID, err)
+			continue
+		}
+		deleteQuietQueueItems(q.ids)
+	}
+}
+
+func deleteQuietQueueItems(ids []int64) {
+	for _, id := range ids {
+		if _, err := db.Exec("DELETE FROM quiet_queue WHERE id = ?", id); err != nil {
+			log.Printf("清理静默队列失败: %v", err)
+		}
+	}
+}
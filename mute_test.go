@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// 把当前时间前后一小时设为 chatID 的静默时段
+func setQuietHoursNow(t *testing.T, chatID string) {
+	now := time.Now().In(time.UTC)
+	minute := now.Hour()*60 + now.Minute()
+	quietMutex.Lock()
+	quietHours[chatID] = QuietHours{Start: (minute + 24*60 - 60) % (24 * 60), End: (minute + 60) % (24 * 60), Location: time.UTC}
+	quietMutex.Unlock()
+	t.Cleanup(func() {
+		quietMutex.Lock()
+		delete(quietHours, chatID)
+		quietMutex.Unlock()
+	})
+}
+
+// Webhook 需要逐条的结构化事件，静默时段内也直接推送
+func TestQuietHoursExemptWebhook(t *testing.T) {
+	setupTestDB(t)
+	notifier := &recordingNotifier{}
+	oldWebhook, registered := notifiers[ChannelWebhook]
+	registerNotifier(ChannelWebhook, notifier)
+	t.Cleanup(func() {
+		if registered {
+			registerNotifier(ChannelWebhook, oldWebhook)
+		} else {
+			delete(notifiers, ChannelWebhook)
+		}
+	})
+	setQuietHoursNow(t, "1")
+
+	changes := Changes{PositionEvents: []PositionEvent{{Type: PositionOpen, Coin: "BTC", NewSize: 1}}}
+	wallet := WalletConfig{Address: "0x5555555555555555555555555555555555555555", Name: "测试", ChatID: "1", Channel: ChannelWebhook, Target: "https://example.com/hook"}
+	if err := notifyWallet(wallet, Notification{Title: "HyperLiquid持仓变化", Text: "新开仓", Changes: &changes}); err != nil {
+		t.Fatal(err)
+	}
+	if notifier.count() != 1 || notifier.notifications[0].Changes == nil {
+		t.Fatalf("webhook notification was not sent with its events: %+v", notifier.notifications)
+	}
+
+	var queued int
+	if err := db.QueryRow("SELECT COUNT(*) FROM quiet_queue").Scan(&queued); err != nil {
+		t.Fatal(err)
+	}
+	if queued != 0 {
+		t.Errorf("%d notifications queued for a webhook", queued)
+	}
+}
+
+// 汇总按地址（不区分大小写）统计账户数，A、B、A 计为 2 个账户
+func TestFlushQuietQueueCountsDistinctAccounts(t *testing.T) {
+	setupTestDB(t)
+	notifier := registerTestNotifier(t)
+
+	a := WalletConfig{Address: "0xAaAaAaAaAaAaAaAaAaAaAaAaAaAaAaAaAaAaAaAa", Name: "A", ChatID: "1", Channel: testChannel}
+	b := WalletConfig{Address: "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", Name: "B", ChatID: "1", Channel: testChannel}
+	lowerA := a
+	lowerA.Address = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	walletMutex.Lock()
+	for _, wallet := range []WalletConfig{a, b, lowerA} {
+		wallets[wallet.ChatID+"_"+wallet.Address] = wallet
+	}
+	walletMutex.Unlock()
+	for _, wallet := range []WalletConfig{a, b, lowerA} {
+		if err := queueQuietNotification(wallet, Notification{Title: "HyperLiquid持仓变化", Text: wallet.Name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	flushQuietQueue(time.Now())
+	if notifier.count() != 1 {
+		t.Fatalf("got %d summaries, want 1", notifier.count())
+	}
+	if name := notifier.notifications[0].Wallet.Name; name != "2个账户" {
+		t.Errorf("summary name = %q, want 2个账户", name)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ChannelTelegram = "telegram"
+	ChannelDiscord  = "discord"
+)
+
+// Notification 是发往任一通知渠道的一条提醒
+//
+// Text 是已渲染的中文文本，所有渠道都可以直接使用；Changes 不为空时，支持富文本的渠道
+// 可以根据事件自行排版。
+//
+// OnSent 不为空时在提醒送达后调用。Telegram、Webhook 和 Matrix 在后台发送，Notify 返回
+// nil 只表示已受理，由渠道在送达后调用；其余渠道在 Notify 成功后调用。
+type Notification struct {
+	Wallet   WalletConfig
+	Title    string
+	Text     string
+	Changes  *Changes
+	Priority NotificationPriority
+	OnSent   func()
+}
+
+// NotificationPriority 决定支持优先级的渠道（Matrix、ntfy、Gotify）以多大动静提醒，
+// 按 低 < 普通 < 高 < 紧急 排序，零值为普通优先级
+type NotificationPriority int
+
+const (
+	PriorityLow NotificationPriority = iota - 1
+	PriorityDefault
+	PriorityHigh
+	PriorityUrgent
+)
+
+// Notifier 把提醒发送到某个渠道，target 为该渠道内的目标（Chat ID、Webhook 地址等）
+type Notifier interface {
+	Notify(target string, notification Notification) error
+}
+
+// backgroundNotifier 是在后台发送的渠道，送达后自行调用 Notification.OnSent
+type backgroundNotifier interface {
+	sendsInBackground()
+}
+
+var notifiers = make(map[string]Notifier)
+
+func registerNotifier(channel string, notifier Notifier) {
+	notifiers[channel] = notifier
+}
+
+// 把提醒发送到订阅配置的渠道，未配置时发到订阅所在的 Telegram 聊天。
+// 已静音的订阅直接跳过；聊天处于静默时段时暂存，结束后合并发送，紧急提醒除外。
+// Webhook 由程序接收，需要逐条的结构化事件，不受静默时段影响。
+// 静音跳过和暂存到静默队列的提醒视为已处理，同样调用 OnSent
+func notifyWallet(wallet WalletConfig, notification Notification) error {
+	now := time.Now()
+	if wallet.isMuted(now) {
+		notification.sent()
+		return nil
+	}
+	channel, target := wallet.destination()
+	if notification.Priority != PriorityUrgent && channel != ChannelWebhook && inQuietHours(wallet.ChatID, now) {
+		if err := queueQuietNotification(wallet, notification); err != nil {
+			return err
+		}
+		notification.sent()
+		return nil
+	}
+	notifier, exists := notifiers[channel]
+	if !exists {
+		return fmt.Errorf("未知的通知渠道: %s", channel)
+	}
+	notification.Wallet = wallet
+	if err := notifier.Notify(target, notification); err != nil {
+		return err
+	}
+	if _, background := notifier.(backgroundNotifier); !background {
+		notification.sent()
+	}
+	return nil
+}
+
+func (n Notification) sent() {
+	if n.OnSent != nil {
+		n.OnSent()
+	}
+}
+
+func (w WalletConfig) destination() (string, string) {
+	if w.Channel == "" || w.Channel == ChannelTelegram {
+		return ChannelTelegram, w.ChatID
+	}
+	return w.Channel, w.Target
+}
+
+// TelegramNotifier 通过发送队列发送文本消息，短时间内同一聊天的多条提醒会合并
+type TelegramNotifier struct{}
+
+func (TelegramNotifier) Notify(target string, notification Notification) error {
+	return telegramOutbox.Enqueue(target, notification.Text, true, notification.OnSent)
+}
+
+func (TelegramNotifier) sendsInBackground() {}
+
+func saveSubscriptionDestinationToDB(chatID, address, channel, target, secret, digest string) error {
+	_, err := db.Exec("UPDATE subscriptions SET channel = ?, target = ?, secret = ?, digest = ? WHERE chat_id = ? AND address = ?",
+		channel, target, secret, digest, chatID, address)
+	return err
+}
+
+func handleTargetCommand(chatID, msgText string) {
+	parts := strings.Fields(msgText)
+	if len(parts) < 3 {
+		sendMessage(chatID, targetUsage())
+		return
+	}
+	address := parts[1]
+	if !isValidHexadecimal(address) {
+		sendMessage(chatID, "无效的地址格式。")
+		return
+	}
+
+	channel, target, secret, digest := parts[2], "", "", DigestImmediate
+	switch channel {
+	case ChannelTelegram:
+		if len(parts) != 3 {
+			sendMessage(chatID, targetUsage())
+			return
+		}
+	case ChannelDiscord:
+		if len(parts) != 4 || !isValidDiscordWebhook(parts[3]) {
+			sendMessage(chatID, "用法: /target <地址> discord <Webhook地址>\nWebhook地址需以 https://discord.com/api/webhooks/ 开头。")
+			return
+		}
+		target = parts[3]
+	case ChannelWebhook:
+		if len(parts) < 4 || len(parts) > 5 || !isValidWebhookURL(parts[3]) {
+			sendMessage(chatID, "用法: /target <地址> webhook <URL> [签名密钥]\nURL需以 https:// 开头且不能是本机或内网地址，未提供密钥时自动生成。")
+			return
+		}
+		target = parts[3]
+		secret = randomHex(32)
+		if len(parts) == 5 {
+			secret = parts[4]
+		}
+	case ChannelFeishu:
+		if len(parts) < 4 || len(parts) > 5 || !isValidFeishuWebhook(parts[3]) {
+			sendMessage(chatID, "用法: /target <地址> feishu <Webhook地址> [签名密钥]\nWebhook地址需为飞书或Lark自定义机器人地址，机器人开启签名校验时需提供密钥。")
+			return
+		}
+		target = parts[3]
+		if len(parts) == 5 {
+			secret = parts[4]
+		}
+	case ChannelDingTalk:
+		if len(parts) < 4 || len(parts) > 5 || !isValidDingTalkWebhook(parts[3]) {
+			sendMessage(chatID, "用法: /target <地址> dingtalk <Webhook地址> [加签密钥]\nWebhook地址需以 https://oapi.dingtalk.com/robot/send?access_token= 开头，机器人开启加签时需提供SEC开头的密钥。")
+			return
+		}
+		target = parts[3]
+		if len(parts) == 5 {
+			secret = parts[4]
+		}
+	case ChannelWeCom:
+		if len(parts) != 4 || !isValidWeComWebhook(parts[3]) {
+			sendMessage(chatID, "用法: /target <地址> wecom <Webhook地址>\nWebhook地址需以 https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key= 开头。")
+			return
+		}
+		target = parts[3]
+	case ChannelMatrix:
+		if len(parts) != 6 || !isValidWebhookURL(parts[3]) || !isValidMatrixRoomID(parts[4]) {
+			sendMessage(chatID, "用法: /target <地址> matrix <Homeserver地址> <房间ID> <Access Token>\nHomeserver地址需以 https:// 开头，房间ID形如 !abc:example.org，可在房间设置的高级选项中找到。")
+			return
+		}
+		target = matrixRoomURL(parts[3], parts[4])
+		secret = parts[5]
+	case ChannelNtfy:
+		if len(parts) < 4 || len(parts) > 5 || !isValidNtfyTopicURL(parts[3]) {
+			sendMessage(chatID, "用法: /target <地址> ntfy <主题地址> [访问令牌|用户名:密码]\n主题地址需以 https:// 开头，形如 https://ntfy.sh/mytopic。")
+			return
+		}
+		target = parts[3]
+		if len(parts) == 5 {
+			secret = parts[4]
+		}
+	case ChannelGotify:
+		if len(parts) != 5 || !isValidWebhookURL(parts[3]) {
+			sendMessage(chatID, "用法: /target <地址> gotify <服务器地址> <应用令牌>\n服务器地址需以 https:// 开头。")
+			return
+		}
+		target = parts[3]
+		secret = parts[4]
+	case ChannelEmail:
+		if _, exists := notifiers[ChannelEmail]; !exists {
+			sendMessage(chatID, "邮件通知未启用，请联系管理员配置SMTP。")
+			return
+		}
+		if len(parts) < 4 || len(parts) > 5 || !isValidEmail(parts[3]) {
+			sendMessage(chatID, "用法: /target <地址> email <邮箱> [immediate|hourly|daily]")
+			return
+		}
+		target = parts[3]
+		if len(parts) == 5 {
+			switch parts[4] {
+			case "immediate":
+			case DigestHourly, DigestDaily:
+				digest = parts[4]
+			default:
+				sendMessage(chatID, "用法: /target <地址> email <邮箱> [immediate|hourly|daily]")
+				return
+			}
+		}
+	default:
+		sendMessage(chatID, targetUsage())
+		return
+	}
+
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+
+	key := chatID + "_" + address
+	wallet, exists := wallets[key]
+	if !exists {
+		sendMessage(chatID, fmt.Sprintf("地址 %s 未被订阅", shortenAddress(address)))
+		return
+	}
+	if err := saveSubscriptionDestinationToDB(chatID, address, channel, target, secret, digest); err != nil {
+		log.Printf("保存通知渠道失败: %v", err)
+		sendMessage(chatID, "保存通知渠道失败，请稍后重试。")
+		return
+	}
+	wallet.Channel = channel
+	wallet.Target = target
+	wallet.Secret = secret
+	wallet.Digest = digest
+	wallets[key] = wallet
+
+	message := fmt.Sprintf("地址 %s 的提醒将发送到 %s", shortenAddress(address), channelName(channel))
+	switch digest {
+	case DigestHourly:
+		message += "（每小时汇总）"
+	case DigestDaily:
+		message += "（每日汇总）"
+	}
+	if channel == ChannelWebhook {
+		message += fmt.Sprintf("\n签名密钥: %s\n请求头 %s 为 sha256=HMAC(密钥, 时间戳.请求体)，时间戳见 %s。", secret, webhookSignatureHeader, webhookTimestampHeader)
+	}
+	sendMessage(chatID, message)
+}
+
+func targetUsage() string {
+	return "用法:\n/target <地址> telegram - 提醒发送到当前聊天\n/target <地址> discord <Webhook地址> - 提醒发送到Discord频道\n/target <地址> webhook <URL> [签名密钥] - 以签名JSON推送到自定义地址\n/target <地址> feishu <Webhook地址> [签名密钥] - 提醒发送到飞书/Lark群\n/target <地址> dingtalk <Webhook地址> [加签密钥] - 提醒发送到钉钉群\n/target <地址> wecom <Webhook地址> - 提醒发送到企业微信群\n/target <地址> matrix <Homeserver地址> <房间ID> <Access Token> - 提醒发送到Matrix房间\n/target <地址> ntfy <主题地址> [访问令牌] - 推送到ntfy主题\n/target <地址> gotify <服务器地址> <应用令牌> - 推送到Gotify\n/target <地址> email <邮箱> [immediate|hourly|daily] - 发送邮件提醒或定时摘要"
+}
+
+// 推送地址只接受 https，并拒绝本机和内网地址，避免机器人被用来访问内部服务
+func isValidWebhookURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return false
+	}
+	return !isInternalHost(parsed.Hostname())
+}
+
+func isInternalHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()
+}
+
+func channelName(channel string) string {
+	switch channel {
+	case ChannelTelegram:
+		return "Telegram"
+	case ChannelDiscord:
+		return "Discord"
+	case ChannelWebhook:
+		return "Webhook"
+	case ChannelFeishu:
+		return "飞书"
+	case ChannelDingTalk:
+		return "钉钉"
+	case ChannelWeCom:
+		return "企业微信"
+	case ChannelMatrix:
+		return "Matrix"
+	case ChannelNtfy:
+		return "ntfy"
+	case ChannelGotify:
+		return "Gotify"
+	case ChannelEmail:
+		return "邮件"
+	}
+	return channel
+}
+
+// botResponse 兼容飞书（code/StatusCode）与钉钉、企业微信（errcode）的返回格式
+type botResponse struct {
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+	StatusCode int    `json:"StatusCode"`
+	ErrCode    int    `json:"errcode"`
+	ErrMsg     string `json:"errmsg"`
+}
+
+// 向群机器人 Webhook 发送 JSON，HTTP 状态码和业务错误码都需要检查
+func postBotMessage(client *http.Client, webhookURL string, payload interface{}, service string) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("转换JSON时出错: %v", err)
+	}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("发送%s消息失败: %v", service, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s返回状态码 %d: %s", service, resp.StatusCode, string(body))
+	}
+	var result botResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析%s响应失败: %v", service, err)
+	}
+	switch {
+	case result.Code != 0:
+		return fmt.Errorf("%s返回错误 %d: %s", service, result.Code, result.Msg)
+	case result.StatusCode != 0:
+		return fmt.Errorf("%s返回错误 %d: %s", service, result.StatusCode, result.Msg)
+	case result.ErrCode != 0:
+		return fmt.Errorf("%s返回错误 %d: %s", service, result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
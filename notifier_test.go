@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+// 推送地址只接受 https，并拒绝本机和内网地址
+func TestIsValidWebhookURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com/hook":                 true,
+		"https://ntfy.sh/mytopic":                  true,
+		"https://8.8.8.8/hook":                     true,
+		"http://example.com/hook":                  false,
+		"ftp://example.com/hook":                   false,
+		"https://":                                 false,
+		"https://localhost:8080/hook":              false,
+		"https://api.localhost/hook":               false,
+		"https://127.0.0.1:9000/hook":              false,
+		"https://10.0.0.5/hook":                    false,
+		"https://192.168.1.10/hook":                false,
+		"https://169.254.169.254/latest/meta-data": false,
+		"https://[::1]/hook":                       false,
+		"https://0.0.0.0/hook":                     false,
+	}
+	for rawURL, want := range tests {
+		if got := isValidWebhookURL(rawURL); got != want {
+			t.Errorf("isValidWebhookURL(%q) = %v, want %v", rawURL, got, want)
+		}
+	}
+}
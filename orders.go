@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+type orderChangeKind int
+
+const (
+	orderPlaced orderChangeKind = iota
+	orderCancelled
+	orderModified
+	orderTriggered
+	orderFilled
+)
+
+type orderChange struct {
+	Kind     orderChangeKind
+	Order    hyperliquid.OpenOrder
+	Previous hyperliquid.OpenOrder
+}
+
+func checkOrders(address string, subscribers []WalletConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	orders, err := hlClient.FrontendOpenOrders(ctx, address)
+	if err != nil {
+		log.Printf("获取 %s 挂单失败: %v", address, err)
+		return
+	}
+	processOrderUpdate(address, subscribers, orders)
+}
+
+// 对比挂单并通知订阅者，轮询和 WebSocket 共用
+func processOrderUpdate(address string, subscribers []WalletConfig, orders []hyperliquid.OpenOrder) {
+	if len(subscribers) == 0 {
+		return
+	}
+
+	currentOrders := make(map[int64]hyperliquid.OpenOrder, len(orders))
+	for _, order := range orders {
+		currentOrders[order.Oid] = order
+	}
+
+	monitorMutex.Lock()
+	state := getOrCreateAccountState(address)
+	// 第一次获取挂单只记录基线，避免把已有挂单当作新挂单
+	if state.LastOrders == nil {
+		saveLastOrders(address, state, currentOrders)
+		monitorMutex.Unlock()
+		return
+	}
+	changes := detectOrderChanges(state.LastOrders, currentOrders)
+	if len(changes) > 0 {
+		saveLastOrders(address, state, currentOrders)
+	}
+	monitorMutex.Unlock()
+
+	if len(changes) == 0 {
+		return
+	}
+	// 查询订单状态需要请求接口，在释放 monitorMutex 之后进行，避免阻塞其他地址的更新
+	resolveClosedOrders(address, changes)
+
+	for _, wallet := range subscribers {
+		message := generateOrderChangesMessage(wallet, changes, state.LastPositions)
+		if err := notifyWallet(wallet, Notification{Title: "HyperLiquid挂单变化", Text: message}); err != nil {
+			log.Printf("发送挂单通知失败 %s (ChatID: %s): %v", address, wallet.ChatID, err)
+		}
+	}
+}
+
+func saveLastOrders(address string, state *AccountState, orders map[int64]hyperliquid.OpenOrder) {
+	next := *state
+	next.LastOrders = orders
+	if !storeAccountState(address, &next) {
+		return
+	}
+	if err := saveAccountStateToDB(address, &next); err != nil {
+		log.Printf("保存账户状态失败 %s: %v", address, err)
+	}
+}
+
+// 消失的挂单先记为撤销，由 resolveClosedOrders 查询实际状态
+func detectOrderChanges(lastOrders, currentOrders map[int64]hyperliquid.OpenOrder) []orderChange {
+	var changes []orderChange
+
+	for oid, current := range currentOrders {
+		last, exists := lastOrders[oid]
+		if !exists {
+			changes = append(changes, orderChange{Kind: orderPlaced, Order: current})
+			continue
+		}
+		// 仅剩余数量减少是部分成交，由成交通知负责
+		if last.LimitPx != current.LimitPx || last.TriggerPx != current.TriggerPx || last.OrigSz != current.OrigSz {
+			changes = append(changes, orderChange{Kind: orderModified, Order: current, Previous: last})
+		}
+	}
+
+	for oid, last := range lastOrders {
+		if _, exists := currentOrders[oid]; exists {
+			continue
+		}
+		changes = append(changes, orderChange{Kind: orderCancelled, Order: last})
+	}
+
+	sortOrderChanges(changes)
+	return changes
+}
+
+func resolveClosedOrders(address string, changes []orderChange) {
+	for i := range changes {
+		if changes[i].Kind == orderCancelled {
+			changes[i].Kind = closedOrderKind(address, changes[i].Order.Oid)
+		}
+	}
+	sortOrderChanges(changes)
+}
+
+func sortOrderChanges(changes []orderChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind < changes[j].Kind
+		}
+		return changes[i].Order.Oid < changes[j].Order.Oid
+	})
+}
+
+// 通过 orderStatus 区分消失的挂单是成交、触发还是撤销
+func closedOrderKind(address string, oid int64) orderChangeKind {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	status, err := hlClient.OrderStatus(ctx, address, oid)
+	if err != nil {
+		log.Printf("查询订单状态失败 %s (%d): %v", address, oid, err)
+		return orderCancelled
+	}
+	if status.Order == nil {
+		return orderCancelled
+	}
+	switch status.Order.Status {
+	case "filled":
+		return orderFilled
+	case "triggered":
+		return orderTriggered
+	}
+	return orderCancelled
+}
+
+func generateOrderChangesMessage(wallet WalletConfig, changes []orderChange, positions map[string]hyperliquid.Position) string {
+	timeStamp := time.Now().Format("2006-01-02 15:04:05")
+	message := fmt.Sprintf("📝 HyperLiquid挂单变化 - %s (%s)\n\n", wallet.Name, timeStamp)
+	message += fmt.Sprintf("💼 账户地址: %s\n\n", shortenAddress(wallet.Address))
+
+	for _, change := range changes {
+		order := change.Order
+		switch change.Kind {
+		case orderPlaced:
+			message += fmt.Sprintf("🆕 新挂单: %s\n", order.Coin)
+		case orderCancelled:
+			message += fmt.Sprintf("🚫 已撤销挂单: %s\n", order.Coin)
+		case orderModified:
+			message += fmt.Sprintf("✏️ 挂单修改: %s\n", order.Coin)
+		case orderTriggered:
+			message += fmt.Sprintf("⚡ 触发单已触发: %s\n", order.Coin)
+		case orderFilled:
+			message += fmt.Sprintf("✅ 挂单已成交: %s\n", order.Coin)
+		}
+		addOrderDetails(&message, order, positions)
+		if change.Kind == orderModified {
+			addOrderModification(&message, change.Previous, order)
+		}
+		message += "\n"
+	}
+	return message
+}
+
+func addOrderDetails(message *string, order hyperliquid.OpenOrder, positions map[string]hyperliquid.Position) {
+	limitPx, _ := strconv.ParseFloat(order.LimitPx, 64)
+	sz, _ := strconv.ParseFloat(order.Sz, 64)
+
+	side := "买入"
+	if order.Side == "A" {
+		side = "卖出"
+	}
+	orderType := order.OrderType
+	if orderType == "" {
+		orderType = "Limit"
+	}
+	flags := ""
+	if order.ReduceOnly {
+		flags = " 只减仓"
+	}
+
+	*message += fmt.Sprintf("   %s %s (%s%s)\n", order.Coin, side, orderType, flags)
+	if order.IsTrigger {
+		triggerPx, _ := strconv.ParseFloat(order.TriggerPx, 64)
+		*message += fmt.Sprintf("   🎯 %s: $%.2f (%s)\n", tpslLabel(order), triggerPx, order.TriggerCondition)
+	}
+	if strings.Contains(order.OrderType, "Market") {
+		*message += fmt.Sprintf("   📦 数量: %.5f\n", sz)
+	} else {
+		*message += fmt.Sprintf("   🏷️ 价格: $%.2f\n", limitPx)
+		*message += fmt.Sprintf("   📦 数量: %.5f ($%.2f)\n", sz, sz*limitPx)
+	}
+
+	// 止盈止损单关联到当前持仓
+	position, exists := positions[order.Coin]
+	if !order.IsTrigger || !exists {
+		return
+	}
+	szi, _ := strconv.ParseFloat(position.Szi, 64)
+	entryPx, _ := strconv.ParseFloat(position.EntryPx, 64)
+	direction := "多头"
+	if szi < 0 {
+		direction = "空头"
+		szi = -szi
+	}
+	*message += fmt.Sprintf("   🔗 对应持仓: %s %.5f, 入场价格 $%.2f\n", direction, szi, entryPx)
+	if entryPx > 0 {
+		triggerPx, _ := strconv.ParseFloat(order.TriggerPx, 64)
+		*message += fmt.Sprintf("   📏 距入场价: %.2f%%\n", (triggerPx-entryPx)/entryPx*100)
+	}
+}
+
+func addOrderModification(message *string, previous, current hyperliquid.OpenOrder) {
+	if previous.LimitPx != current.LimitPx {
+		*message += fmt.Sprintf("   价格: %s → %s\n", previous.LimitPx, current.LimitPx)
+	}
+	if previous.TriggerPx != current.TriggerPx {
+		*message += fmt.Sprintf("   触发价格: %s → %s\n", previous.TriggerPx, current.TriggerPx)
+	}
+	if previous.OrigSz != current.OrigSz {
+		*message += fmt.Sprintf("   数量: %s → %s\n", previous.OrigSz, current.OrigSz)
+	}
+}
+
+func tpslLabel(order hyperliquid.OpenOrder) string {
+	switch {
+	case strings.HasPrefix(order.OrderType, "Take Profit"):
+		return "止盈触发价"
+	case strings.HasPrefix(order.OrderType, "Stop"):
+		return "止损触发价"
+	}
+	return "触发价格"
+}
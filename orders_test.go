@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+// 查询已消失挂单的状态时不应持有 monitorMutex
+func TestOrderStatusLookupDoesNotHoldMonitorMutex(t *testing.T) {
+	setupTestDB(t)
+	notifier := registerTestNotifier(t)
+
+	queried := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(queried)
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{"status": "order", "order": map[string]any{"status": "filled"}})
+	}))
+	oldClient := hlClient
+	hlClient = hyperliquid.NewClient(hyperliquid.WithBaseURL(server.URL))
+	t.Cleanup(func() {
+		server.Close()
+		hlClient = oldClient
+	})
+
+	address := "0x8888888888888888888888888888888888888888"
+	subscribers := []WalletConfig{{Address: address, Name: "测试", ChatID: "1", Channel: testChannel}}
+	order := hyperliquid.OpenOrder{Coin: "BTC", Oid: 42, Side: "B", LimitPx: "100", Sz: "1", OrigSz: "1"}
+	processOrderUpdate(address, subscribers, []hyperliquid.OpenOrder{order})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		processOrderUpdate(address, subscribers, nil)
+	}()
+	<-queried
+
+	locked := make(chan struct{})
+	go func() {
+		monitorMutex.Lock()
+		monitorMutex.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("monitorMutex held during the orderStatus request")
+	}
+	close(release)
+	<-done
+
+	if notifier.count() != 1 {
+		t.Fatalf("got %d notifications, want 1", notifier.count())
+	}
+	if text := notifier.notifications[0].Text; !strings.Contains(text, "挂单已成交: BTC") {
+		t.Errorf("notification does not use the resolved status:\n%s", text)
+	}
+}
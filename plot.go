@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// 图表尺寸，按 vgimg 默认的 96 DPI 渲染为 900x500 像素
+const (
+	chartDPI    = 96
+	chartWidth  = 900 * vg.Inch / chartDPI
+	chartHeight = 500 * vg.Inch / chartDPI
+)
+
+var (
+	chartAxisColor = color.RGBA{0x55, 0x55, 0x55, 0xff}
+	chartGridColor = color.RGBA{0xe5, 0xe5, 0xe5, 0xff}
+
+	// 多条曲线依次使用的颜色
+	chartPalette = []color.RGBA{
+		{0x34, 0x98, 0xdb, 0xff},
+		{0xe6, 0x7e, 0x22, 0xff},
+		{0x2e, 0xcc, 0x71, 0xff},
+		{0xe7, 0x4c, 0x3c, 0xff},
+		{0x9b, 0x59, 0xb6, 0xff},
+		{0x16, 0xa0, 0x85, 0xff},
+	}
+)
+
+type chartPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+type chartSeries struct {
+	Name   string
+	Points []chartPoint
+}
+
+// lineChart 是以时间为横轴的折线图，由 gonum/plot 渲染。内置的 Liberation 字体只包含西文字符，标题和图例使用英文
+type lineChart struct {
+	Title       string
+	Series      []chartSeries
+	IncludeZero bool // 纵轴始终包含 0，盈亏和带方向的仓位图使用
+}
+
+func (c lineChart) RenderPNG() ([]byte, error) {
+	minTime, maxTime, ok := c.timeRange()
+	if !ok {
+		return nil, fmt.Errorf("没有可绘制的数据")
+	}
+
+	p := plot.New()
+	p.Title.Text = c.Title
+	p.Legend.Top = true
+	p.Legend.Left = true
+	layout := "01-02 15:04"
+	if maxTime.Sub(minTime) > 3*24*time.Hour {
+		layout = "01-02"
+	}
+	p.X.Tick.Marker = plot.TimeTicks{Format: layout, Time: plot.UnixTimeIn(time.Local)}
+	p.Y.Tick.Marker = compactTicks{}
+
+	grid := plotter.NewGrid()
+	grid.Vertical.Color = chartGridColor
+	grid.Horizontal.Color = chartGridColor
+	p.Add(grid)
+
+	for i, series := range c.Series {
+		xys := make(plotter.XYs, len(series.Points))
+		for j, point := range series.Points {
+			xys[j].X = float64(point.Time.Unix())
+			xys[j].Y = point.Value
+		}
+		seriesColor := chartPalette[i%len(chartPalette)]
+		if len(xys) == 1 {
+			scatter, err := plotter.NewScatter(xys)
+			if err != nil {
+				return nil, fmt.Errorf("生成图表失败: %v", err)
+			}
+			scatter.Color = seriesColor
+			p.Add(scatter)
+			p.Legend.Add(series.Name, scatter)
+			continue
+		}
+		line, err := plotter.NewLine(xys)
+		if err != nil {
+			return nil, fmt.Errorf("生成图表失败: %v", err)
+		}
+		line.Color = seriesColor
+		line.Width = vg.Points(1.5)
+		p.Add(line)
+		p.Legend.Add(series.Name, line)
+	}
+
+	if c.IncludeZero {
+		p.Y.Min = math.Min(p.Y.Min, 0)
+		p.Y.Max = math.Max(p.Y.Max, 0)
+		zero := plotter.NewFunction(func(float64) float64 { return 0 })
+		zero.Color = chartAxisColor
+		zero.Width = vg.Points(0.5)
+		p.Add(zero)
+	}
+	if p.Y.Max-p.Y.Min < 1e-9 {
+		padding := math.Max(math.Abs(p.Y.Max)*0.05, 1)
+		p.Y.Min -= padding
+		p.Y.Max += padding
+	}
+	if maxTime.Equal(minTime) {
+		p.X.Max = p.X.Min + 60
+	}
+
+	writer, err := p.WriterTo(chartWidth, chartHeight, "png")
+	if err != nil {
+		return nil, fmt.Errorf("生成图表失败: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := writer.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("编码PNG失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (c lineChart) timeRange() (minTime, maxTime time.Time, ok bool) {
+	for _, series := range c.Series {
+		for _, point := range series.Points {
+			if !ok || point.Time.Before(minTime) {
+				minTime = point.Time
+			}
+			if !ok || point.Time.After(maxTime) {
+				maxTime = point.Time
+			}
+			ok = true
+		}
+	}
+	return minTime, maxTime, ok
+}
+
+// compactTicks 沿用 gonum/plot 的默认刻度，标签改为 1.5k、2M 这样的简写
+type compactTicks struct{}
+
+func (compactTicks) Ticks(min, max float64) []plot.Tick {
+	ticks := plot.DefaultTicks{}.Ticks(min, max)
+	for i := range ticks {
+		if ticks[i].Label != "" {
+			ticks[i].Label = formatCompact(ticks[i].Value)
+		}
+	}
+	return ticks
+}
+
+func formatCompact(value float64) string {
+	abs := math.Abs(value)
+	switch {
+	case abs >= 1e9:
+		return fmt.Sprintf("%.4gB", value/1e9)
+	case abs >= 1e6:
+		return fmt.Sprintf("%.4gM", value/1e6)
+	case abs >= 1e3:
+		return fmt.Sprintf("%.4gk", value/1e3)
+	}
+	return fmt.Sprintf("%.4g", value)
+}
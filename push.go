@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ChannelNtfy   = "ntfy"
+	ChannelGotify = "gotify"
+
+	ntfyMaxBytes = 4096 // 超过后 ntfy 会把消息转为附件
+)
+
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title,omitempty"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// NtfyNotifier 向 ntfy 主题推送消息，target 为主题地址（例如 https://ntfy.sh/mytopic），
+// 订阅的 Secret 为访问令牌或 "用户名:密码"，为空时匿名发送
+type NtfyNotifier struct {
+	HTTPClient *http.Client
+}
+
+func NewNtfyNotifier() *NtfyNotifier {
+	return &NtfyNotifier{HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *NtfyNotifier) Notify(target string, notification Notification) error {
+	// JSON 方式需要发到服务器根地址，主题放在消息体中
+	split := strings.LastIndex(target, "/")
+	message := ntfyMessage{
+		Topic:    target[split+1:],
+		Title:    notification.Title,
+		Message:  truncateBytes(notification.Text, ntfyMaxBytes),
+		Priority: ntfyPriority(notification.Priority),
+	}
+	if notification.Priority == PriorityUrgent {
+		message.Tags = []string{"rotating_light"}
+	}
+
+	req, err := newPushRequest(target[:split], message)
+	if err != nil {
+		return err
+	}
+	if secret := notification.Wallet.Secret; secret != "" {
+		if username, password, found := strings.Cut(secret, ":"); found {
+			req.SetBasicAuth(username, password)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+	}
+	return doPushRequest(n.HTTPClient, req, "ntfy")
+}
+
+// ntfy 优先级为 1-5，5 会在手机上持续响铃
+func ntfyPriority(priority NotificationPriority) int {
+	switch priority {
+	case PriorityLow:
+		return 2
+	case PriorityHigh:
+		return 4
+	case PriorityUrgent:
+		return 5
+	}
+	return 3
+}
+
+type gotifyMessage struct {
+	Title    string `json:"title,omitempty"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+// GotifyNotifier 向 Gotify 服务器推送消息，target 为服务器地址，订阅的 Secret 为应用令牌
+type GotifyNotifier struct {
+	HTTPClient *http.Client
+}
+
+func NewGotifyNotifier() *GotifyNotifier {
+	return &GotifyNotifier{HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (g *GotifyNotifier) Notify(target string, notification Notification) error {
+	message := gotifyMessage{
+		Title:    notification.Title,
+		Message:  notification.Text,
+		Priority: gotifyPriority(notification.Priority),
+	}
+	req, err := newPushRequest(strings.TrimRight(target, "/")+"/message", message)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Gotify-Key", notification.Wallet.Secret)
+	return doPushRequest(g.HTTPClient, req, "Gotify")
+}
+
+// Gotify 优先级为 0-10，Android 客户端在 8 及以上时弹出并响铃
+func gotifyPriority(priority NotificationPriority) int {
+	switch priority {
+	case PriorityLow:
+		return 2
+	case PriorityHigh:
+		return 8
+	case PriorityUrgent:
+		return 10
+	}
+	return 5
+}
+
+func newPushRequest(endpoint string, message interface{}) (*http.Request, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("转换JSON时出错: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("创建推送请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func doPushRequest(client *http.Client, req *http.Request, service string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送%s消息失败: %v", service, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s返回状态码 %d: %s", service, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// ntfy 主题地址需包含服务器和主题两部分
+func isValidNtfyTopicURL(url string) bool {
+	if !isValidWebhookURL(url) {
+		return false
+	}
+	rest := url[strings.Index(url, "://")+3:]
+	split := strings.LastIndex(rest, "/")
+	return split > 0 && split < len(rest)-1
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+// Renderer 把检测结果和账户快照转换为某种输出格式
+type Renderer interface {
+	RenderChanges(wallet WalletConfig, changes Changes) (string, error)
+	RenderInitialStatus(wallet WalletConfig, positions map[string]hyperliquid.Position, summary AccountSummary, now time.Time) (string, error)
+}
+
+var textRenderer Renderer = TextRenderer{}
+
+// TextRenderer 输出 Telegram 使用的中文文本
+type TextRenderer struct{}
+
+func (TextRenderer) RenderChanges(wallet WalletConfig, changes Changes) (string, error) {
+	if changes.Empty() {
+		return "", nil
+	}
+	timeStamp := changes.Time.Format("2006-01-02 15:04:05")
+	message := fmt.Sprintf("🔄 HyperLiquid持仓变化 - %s (%s)\n\n", wallet.Name, timeStamp)
+	message += fmt.Sprintf("💼 账户地址: %s\n\n", shortenAddress(wallet.Address))
+
+	for _, event := range changes.PositionEvents {
+		message += renderPositionEvent(event)
+	}
+	for _, event := range changes.AccountEvents {
+		message += renderAccountEvent(event)
+	}
+	return message, nil
+}
+
+func (TextRenderer) RenderInitialStatus(wallet WalletConfig, positions map[string]hyperliquid.Position, summary AccountSummary, now time.Time) (string, error) {
+	timeStamp := now.Format("2006-01-02 15:04:05")
+	message := fmt.Sprintf("🔄 HyperLiquid初始持仓状态 - %s (%s)\n\n", wallet.Name, timeStamp)
+	message += fmt.Sprintf("💼 账户地址: %s\n", shortenAddress(wallet.Address))
+	message += fmt.Sprintf("💰 账户价值: $%.2f\n", summary.AccountValue)
+	message += fmt.Sprintf("💵 可提取金额: $%.2f\n\n", summary.Withdrawable)
+
+	if len(positions) > 0 {
+		message += "📊 当前持仓:\n\n"
+		for _, view := range positionViews(positions) {
+			message += fmt.Sprintf("🪙 %s (%s)\n", view.Coin, view.Direction)
+			message += fmt.Sprintf("📈 仓位大小: %.5f ($%.2f)\n", view.Size, view.Value)
+			message += fmt.Sprintf("🏷️ 入场价格: $%.2f\n", view.EntryPx)
+			message += fmt.Sprintf("📊 杠杆: %dx (%s)\n", view.Leverage, view.LeverageType)
+			pnlEmoji := "🔴"
+			if view.UnrealizedPnl >= 0 {
+				pnlEmoji = "🟢"
+			}
+			message += fmt.Sprintf("%s 盈亏: $%.2f (%.2f%%)\n", pnlEmoji, view.UnrealizedPnl, view.RoiPercent)
+			message += fmt.Sprintf("⚠️ 强平价格: $%.2f\n", view.LiquidationPx)
+			message += fmt.Sprintf("💸 已用保证金: $%.2f\n\n", view.MarginUsed)
+		}
+	} else {
+		message += "没有找到开放的持仓。\n"
+	}
+	message += "🔔 持仓监控已启动，将在仓位变化时发送通知。"
+	return message, nil
+}
+
+func renderPositionEvent(event PositionEvent) string {
+	message := ""
+	switch event.Type {
+	case PositionOpen:
+		message += fmt.Sprintf("🆕 新开仓位: %s\n", event.Coin)
+		addPositionDetails(&message, event.Position)
+	case PositionIncrease, PositionDecrease:
+		if event.Type == PositionIncrease {
+			message += fmt.Sprintf("📈 仓位增加: %s\n", event.Coin)
+		} else {
+			message += fmt.Sprintf("📉 仓位减少: %s\n", event.Coin)
+		}
+		message += fmt.Sprintf("   从: %.5f\n", event.OldSize)
+		message += fmt.Sprintf("   到: %.5f\n", event.NewSize)
+		message += fmt.Sprintf("   变化: %.2f%%\n\n", event.ChangePercent)
+	case PositionFlip:
+		message += fmt.Sprintf("🔁 仓位反手: %s (%s → %s)\n", event.Coin, sizeDirection(event.OldSize), sizeDirection(event.NewSize))
+		message += fmt.Sprintf("   从: %s %.5f\n", sizeDirection(event.OldSize), math.Abs(event.OldSize))
+		message += fmt.Sprintf("   到: %s %.5f\n", sizeDirection(event.NewSize), math.Abs(event.NewSize))
+		entryPx, _ := strconv.ParseFloat(event.Position.EntryPx, 64)
+		message += fmt.Sprintf("   🏷️ 新入场价格: $%.2f\n\n", entryPx)
+	case PositionClose:
+		message += fmt.Sprintf("❌ 已关闭仓位: %s\n\n", event.Coin)
+	case PositionMarginMode:
+		message += fmt.Sprintf("🔀 保证金模式切换: %s\n", event.Coin)
+		message += fmt.Sprintf("   从: %s\n", marginModeName(event.OldMode))
+		message += fmt.Sprintf("   到: %s\n\n", marginModeName(event.NewMode))
+	case PositionLeverage:
+		if event.NewValue > event.OldValue {
+			message += fmt.Sprintf("⏫ 杠杆提高: %s\n", event.Coin)
+		} else {
+			message += fmt.Sprintf("⏬ 杠杆降低: %s\n", event.Coin)
+		}
+		message += fmt.Sprintf("   从: %.0fx\n", event.OldValue)
+		message += fmt.Sprintf("   到: %.0fx\n\n", event.NewValue)
+	case PositionIsolatedMargin:
+		if event.ChangePercent > 0 {
+			message += fmt.Sprintf("➕ 追加逐仓保证金: %s\n", event.Coin)
+		} else {
+			message += fmt.Sprintf("➖ 减少逐仓保证金: %s\n", event.Coin)
+		}
+		message += fmt.Sprintf("   从: $%.2f\n", event.OldValue)
+		message += fmt.Sprintf("   到: $%.2f\n", event.NewValue)
+		message += fmt.Sprintf("   变化: %+.2f%%\n\n", event.ChangePercent)
+	case PositionPnl:
+		message += fmt.Sprintf("💹 盈亏变化: %s\n", event.Coin)
+		message += fmt.Sprintf("   从: $%.2f\n", event.OldValue)
+		message += fmt.Sprintf("   到: $%.2f\n", event.NewValue)
+		message += fmt.Sprintf("   变化: $%+.2f\n\n", event.NewValue-event.OldValue)
+	}
+	return message
+}
+
+func renderAccountEvent(event AccountEvent) string {
+	label := ""
+	switch event.Type {
+	case AccountValueChange:
+		label = "💰 账户价值变化"
+	case WithdrawableChange:
+		label = "💵 可提取金额变化"
+	case MarginUsedChange:
+		label = "💸 已用保证金变化"
+	}
+	message := fmt.Sprintf("%s\n", label)
+	message += fmt.Sprintf("   从: $%.2f\n", event.OldValue)
+	message += fmt.Sprintf("   到: $%.2f\n", event.NewValue)
+	message += fmt.Sprintf("   变化: %+.2f%% ($%+.2f)\n\n", event.ChangePercent, event.NewValue-event.OldValue)
+	return message
+}
+
+func addPositionDetails(message *string, position hyperliquid.Position) {
+	szi, _ := strconv.ParseFloat(position.Szi, 64)
+	entryPx, _ := strconv.ParseFloat(position.EntryPx, 64)
+	posValue, _ := strconv.ParseFloat(position.PositionValue, 64)
+	unrealizedPnl, _ := strconv.ParseFloat(position.UnrealizedPnl, 64)
+	roi, _ := strconv.ParseFloat(position.ReturnOnEquity, 64)
+	liquidationPx, _ := strconv.ParseFloat(position.LiquidationPx, 64)
+
+	direction := "多头"
+	if szi < 0 {
+		direction = "空头"
+		szi = -szi
+	}
+
+	*message += fmt.Sprintf("   %s (%s)\n", position.Coin, direction)
+	*message += fmt.Sprintf("   📈 仓位大小: %.5f ($%.2f)\n", szi, posValue)
+	*message += fmt.Sprintf("   🏷️ 入场价格: $%.2f\n", entryPx)
+	*message += fmt.Sprintf("   📊 杠杆: %dx\n", position.Leverage.Value)
+	pnlEmoji := "🔴"
+	if unrealizedPnl >= 0 {
+		pnlEmoji = "🟢"
+	}
+	*message += fmt.Sprintf("   %s 盈亏: $%.2f (%.2f%%)\n", pnlEmoji, unrealizedPnl, roi*100)
+	*message += fmt.Sprintf("   ⚠️ 强平价格: $%.2f\n\n", liquidationPx)
+}
+
+func sizeDirection(szi float64) string {
+	if szi < 0 {
+		return "空头"
+	}
+	return "多头"
+}
+
+func marginModeName(leverageType string) string {
+	switch leverageType {
+	case "cross":
+		return "全仓"
+	case "isolated":
+		return "逐仓"
+	}
+	return leverageType
+}
+
+// positionView 是解析后的仓位数据，文本和 HTML 渲染共用
+type positionView struct {
+	Coin          string
+	Direction     string
+	Size          float64 // 绝对值
+	Value         float64
+	EntryPx       float64
+	Leverage      int
+	LeverageType  string
+	UnrealizedPnl float64
+	RoiPercent    float64
+	LiquidationPx float64
+	MarginUsed    float64
+}
+
+func newPositionView(position hyperliquid.Position) positionView {
+	view := positionView{
+		Coin:         position.Coin,
+		Direction:    "多头",
+		Leverage:     position.Leverage.Value,
+		LeverageType: position.Leverage.Type,
+	}
+	szi, _ := strconv.ParseFloat(position.Szi, 64)
+	view.EntryPx, _ = strconv.ParseFloat(position.EntryPx, 64)
+	view.Value, _ = strconv.ParseFloat(position.PositionValue, 64)
+	view.UnrealizedPnl, _ = strconv.ParseFloat(position.UnrealizedPnl, 64)
+	roi, _ := strconv.ParseFloat(position.ReturnOnEquity, 64)
+	view.RoiPercent = roi * 100
+	view.LiquidationPx, _ = strconv.ParseFloat(position.LiquidationPx, 64)
+	view.MarginUsed, _ = strconv.ParseFloat(position.MarginUsed, 64)
+	if szi < 0 {
+		view.Direction = "空头"
+		szi = -szi
+	}
+	view.Size = szi
+	return view
+}
+
+func positionViews(positions map[string]hyperliquid.Position) []positionView {
+	sorted := sortedPositions(positions)
+	views := make([]positionView, len(sorted))
+	for i, position := range sorted {
+		views[i] = newPositionView(position)
+	}
+	return views
+}
+
+func sortedPositions(positions map[string]hyperliquid.Position) []hyperliquid.Position {
+	sorted := make([]hyperliquid.Position, 0, len(positions))
+	for _, position := range positions {
+		sorted = append(sorted, position)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Coin < sorted[j].Coin
+	})
+	return sorted
+}
+
+// markdownSection 是 Markdown 类渠道（飞书、钉钉、企业微信）中的一段内容：一行标题加若干明细
+type markdownSection struct {
+	Title string
+	Lines []string
+	Color int // 与 Discord 嵌入相同的配色，各渠道再映射到自己支持的颜色
+}
+
+// 有事件时每个事件一段，否则整段文本作为一段，首行作为标题
+func markdownSections(notification Notification) []markdownSection {
+	if notification.Changes == nil || notification.Changes.Empty() {
+		lines := splitLines(notification.Text)
+		if len(lines) == 0 {
+			return []markdownSection{{Title: notification.Title, Color: discordColorBlue}}
+		}
+		return []markdownSection{{Title: lines[0], Lines: lines[1:], Color: discordColorBlue}}
+	}
+
+	var sections []markdownSection
+	for _, event := range notification.Changes.PositionEvents {
+		lines := splitLines(renderPositionEvent(event))
+		sections = append(sections, markdownSection{Title: lines[0], Lines: lines[1:], Color: positionEventColor(event.Type)})
+	}
+	for _, event := range notification.Changes.AccountEvents {
+		lines := splitLines(renderAccountEvent(event))
+		sections = append(sections, markdownSection{Title: lines[0], Lines: lines[1:], Color: discordColorBlue})
+	}
+	return sections
+}
+
+// 按行拆分并去掉缩进和空行
+func splitLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ReportDaily  = "daily"
+	ReportWeekly = "weekly"
+
+	reportRetryDelay    = 10 * time.Minute
+	reportFillsMaxPages = 20
+)
+
+var reportWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+var weekdayNames = []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// 正在发送或发送失败的定期报告，值为最早可以再次尝试的时间，由 walletMutex 保护
+var reportRetryAt = make(map[string]time.Time)
+
+// ReportSchedule 是订阅的定期报告设置，Period 为空表示未开启
+type ReportSchedule struct {
+	Period   string
+	Minute   int // 当地时间一天中的第几分钟
+	Weekday  time.Weekday
+	Location *time.Location
+	LastSent time.Time
+}
+
+// 不晚于 now 的最近一次计划发送时间
+func (r ReportSchedule) latestDue(now time.Time) time.Time {
+	local := now.In(r.Location)
+	due := time.Date(local.Year(), local.Month(), local.Day(), r.Minute/60, r.Minute%60, 0, 0, r.Location)
+	if r.Period == ReportWeekly {
+		due = due.AddDate(0, 0, -((int(local.Weekday()) - int(r.Weekday) + 7) % 7))
+	}
+	if due.After(now) {
+		if r.Period == ReportWeekly {
+			due = due.AddDate(0, 0, -7)
+		} else {
+			due = due.AddDate(0, 0, -1)
+		}
+	}
+	return due
+}
+
+func (r ReportSchedule) length() time.Duration {
+	if r.Period == ReportWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+func (r ReportSchedule) String() string {
+	clock := fmt.Sprintf("%02d:%02d", r.Minute/60, r.Minute%60)
+	if r.Period == ReportWeekly {
+		return fmt.Sprintf("每周%s %s (%s)", strings.TrimPrefix(weekdayNames[r.Weekday], "周"), clock, r.Location)
+	}
+	return fmt.Sprintf("每天 %s (%s)", clock, r.Location)
+}
+
+func reportScheduleFromDB(period string, minute, weekday int, timezone string, lastSent int64) ReportSchedule {
+	if period == "" {
+		return ReportSchedule{}
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Printf("无效的报告时区 %s: %v", timezone, err)
+		return ReportSchedule{}
+	}
+	return ReportSchedule{
+		Period:   period,
+		Minute:   minute,
+		Weekday:  time.Weekday(weekday),
+		Location: location,
+		LastSent: time.Unix(lastSent, 0),
+	}
+}
+
+func saveReportScheduleToDB(chatID, address string, schedule ReportSchedule) error {
+	timezone := ""
+	if schedule.Location != nil {
+		timezone = schedule.Location.String()
+	}
+	_, err := db.Exec(`
+        UPDATE subscriptions SET report_period = ?, report_minute = ?, report_weekday = ?, report_timezone = ?, report_last_sent = ?
+        WHERE chat_id = ? AND address = ?
+    `, schedule.Period, schedule.Minute, int(schedule.Weekday), timezone, schedule.LastSent.Unix(), chatID, address)
+	return err
+}
+
+// 聊天设置了静默时段时沿用其时区
+func chatTimezone(chatID string) *time.Location {
+	quietMutex.Lock()
+	defer quietMutex.Unlock()
+	if q, exists := quietHours[chatID]; exists {
+		return q.Location
+	}
+	location, _ := time.LoadLocation(defaultTimezone)
+	return location
+}
+
+func reportUsage() string {
+	return "用法:\n/report - 查看已开启的定期报告\n/report <地址|名称|#标签> daily 09:00 [时区] - 每日报告\n/report <地址|名称|#标签> weekly mon 09:00 [时区] - 每周报告\n/report <地址|名称|#标签> now [daily|weekly] - 立即生成一份报告\n/report <地址|名称|#标签> off - 关闭定期报告"
+}
+
+func handleReportCommand(chatID, msgText string) {
+	parts := strings.Fields(msgText)
+	if len(parts) == 1 {
+		listReportSchedules(chatID)
+		return
+	}
+
+	// 选择器可能是带空格的名称，以第一个关键字为界
+	keyword := -1
+	for i := 2; i < len(parts); i++ {
+		switch parts[i] {
+		case ReportDaily, ReportWeekly, "off", "now":
+			keyword = i
+		}
+		if keyword > 0 {
+			break
+		}
+	}
+	if keyword < 0 {
+		sendMessage(chatID, reportUsage())
+		return
+	}
+	selector := strings.Join(parts[1:keyword], " ")
+	args := parts[keyword:]
+
+	walletMutex.Lock()
+	matched := matchWallets(chatID, selector)
+	walletMutex.Unlock()
+	if len(matched) == 0 {
+		sendMessage(chatID, fmt.Sprintf("未找到匹配 %s 的订阅", selector))
+		return
+	}
+
+	var schedule ReportSchedule
+	switch args[0] {
+	case "now":
+		period := ReportDaily
+		if len(args) == 2 && args[1] == ReportWeekly {
+			period = ReportWeekly
+		}
+		sendMessage(chatID, "正在生成报告…")
+		now := time.Now()
+		for _, wallet := range matched {
+			go func(wallet WalletConfig) {
+				if err := sendPerformanceReport(wallet, ReportSchedule{Period: period}, now, nil); err != nil {
+					sendMessage(chatID, fmt.Sprintf("%s 的报告%v", shortenAddress(wallet.Address), err))
+				}
+			}(wallet)
+		}
+		return
+	case "off":
+	case ReportDaily, ReportWeekly:
+		rest := args[1:]
+		if args[0] == ReportWeekly {
+			if len(rest) == 0 {
+				sendMessage(chatID, reportUsage())
+				return
+			}
+			weekday, exists := reportWeekdays[strings.ToLower(rest[0])]
+			if !exists {
+				sendMessage(chatID, "无效的星期，请使用 mon、tue、wed、thu、fri、sat、sun。")
+				return
+			}
+			schedule.Weekday = weekday
+			rest = rest[1:]
+		}
+		if len(rest) == 0 || len(rest) > 2 {
+			sendMessage(chatID, reportUsage())
+			return
+		}
+		minute, ok := parseClock(rest[0])
+		if !ok {
+			sendMessage(chatID, "无效的时间，请使用 HH:MM 格式，例如 09:00。")
+			return
+		}
+		location := chatTimezone(chatID)
+		if len(rest) == 2 {
+			var err error
+			if location, err = time.LoadLocation(rest[1]); err != nil {
+				sendMessage(chatID, fmt.Sprintf("无效的时区: %s，请使用如 Asia/Shanghai、UTC 的时区名称。", rest[1]))
+				return
+			}
+		}
+		schedule.Period = args[0]
+		schedule.Minute = minute
+		schedule.Location = location
+		// 从现在开始计算，避免设置后立即补发
+		schedule.LastSent = time.Now()
+	default:
+		sendMessage(chatID, reportUsage())
+		return
+	}
+
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+	for _, wallet := range matched {
+		if err := saveReportScheduleToDB(chatID, wallet.Address, schedule); err != nil {
+			log.Printf("保存报告设置失败: %v", err)
+			sendMessage(chatID, "保存报告设置失败，请稍后重试。")
+			return
+		}
+		key := chatID + "_" + wallet.Address
+		if current, exists := wallets[key]; exists {
+			current.Report = schedule
+			wallets[key] = current
+		}
+	}
+	if schedule.Period == "" {
+		sendMessage(chatID, fmt.Sprintf("已关闭 %d 个订阅的定期报告", len(matched)))
+		return
+	}
+	sendMessage(chatID, fmt.Sprintf("📅 已为 %d 个订阅开启定期报告: %s", len(matched), schedule))
+}
+
+func listReportSchedules(chatID string) {
+	walletMutex.Lock()
+	message := "📅 定期报告:\n\n"
+	count := 0
+	for _, wallet := range chatWallets(chatID) {
+		if wallet.Report.Period == "" {
+			continue
+		}
+		count++
+		message += fmt.Sprintf("%d. %s (%s) - %s\n", count, wallet.Name, shortenAddress(wallet.Address), wallet.Report)
+	}
+	walletMutex.Unlock()
+
+	if count == 0 {
+		sendMessage(chatID, "尚未开启定期报告。\n\n"+reportUsage())
+		return
+	}
+	sendMessage(chatID, message)
+}
+
+// 每分钟检查一次到期的报告，停机期间错过的报告只补发最近的一份。
+// 渠道确认送达后才更新发送时间，发送失败或被发送队列丢弃的报告在 reportRetryDelay 后重试
+func runReportScheduler() {
+	for {
+		now := time.Now()
+		for key, wallet := range claimDueReports(now) {
+			go deliverScheduledReport(key, wallet, now)
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+// 取出到期且不在重试等待中的报告，并在发送结束前阻止重复发送
+func claimDueReports(now time.Time) map[string]WalletConfig {
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+	due := make(map[string]WalletConfig)
+	for key, wallet := range wallets {
+		if wallet.Report.Period == "" || !wallet.Report.latestDue(now).After(wallet.Report.LastSent) || now.Before(reportRetryAt[key]) {
+			continue
+		}
+		reportRetryAt[key] = now.Add(reportRetryDelay)
+		due[key] = wallet
+	}
+	return due
+}
+
+// Telegram 等渠道在后台发送，送达时才通过 OnSent 记录发送时间
+func deliverScheduledReport(key string, wallet WalletConfig, now time.Time) {
+	err := sendPerformanceReport(wallet, wallet.Report, now, func() { markReportSent(key, now) })
+	if err != nil {
+		log.Printf("%v 后重试 %s 的定期报告", reportRetryDelay, wallet.Address)
+	}
+}
+
+func markReportSent(key string, sent time.Time) {
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+	delete(reportRetryAt, key)
+	wallet, exists := wallets[key]
+	// 发送期间报告可能被关闭或重新设置
+	if !exists || wallet.Report.Period == "" || !sent.After(wallet.Report.LastSent) {
+		return
+	}
+	wallet.Report.LastSent = sent
+	wallets[key] = wallet
+	if err := saveReportScheduleToDB(wallet.ChatID, wallet.Address, wallet.Report); err != nil {
+		log.Printf("保存报告发送时间失败 %s: %v", wallet.Address, err)
+	}
+}
+
+// onSent 在报告送达后调用，可以为 nil
+func sendPerformanceReport(wallet WalletConfig, schedule ReportSchedule, end time.Time, onSent func()) error {
+	message, err := buildPerformanceReport(wallet, schedule, end)
+	if err != nil {
+		log.Printf("生成报告失败 %s: %v", wallet.Address, err)
+		return fmt.Errorf("生成失败: %v", err)
+	}
+	if err := notifyWallet(wallet, Notification{Title: "HyperLiquid定期报告", Text: message, OnSent: onSent}); err != nil {
+		log.Printf("发送报告失败 %s (ChatID: %s): %v", wallet.Address, wallet.ChatID, err)
+		return fmt.Errorf("发送失败: %v", err)
+	}
+	return nil
+}
+
+// 期初权益取期间内最早的快照，最大盈亏取自往返交易记录，其余数据来自实时状态、成交和资金费记录
+func buildPerformanceReport(wallet WalletConfig, schedule ReportSchedule, end time.Time) (string, error) {
+	start := end.Add(-schedule.length())
+	positions, summary, err := fetchPositions(wallet.Address)
+	if err != nil {
+		return "", err
+	}
+
+	fills, _, complete, err := fetchFillsAfter(wallet.Address, FillCursor{Time: start.UnixMilli()}, end.UnixMilli(), reportFillsMaxPages)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	funding, err := hlClient.UserFunding(ctx, wallet.Address, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return "", fmt.Errorf("获取资金费记录失败: %v", err)
+	}
+	if err := syncTrades(wallet.Address); err != nil {
+		log.Printf("同步 %s 交易记录失败: %v", wallet.Address, err)
+	}
+	trades, err := loadTrades(strings.ToLower(wallet.Address), false, start)
+	if err != nil {
+		return "", fmt.Errorf("读取交易记录失败: %v", err)
+	}
+
+	title := "每日报告"
+	if schedule.Period == ReportWeekly {
+		title = "每周报告"
+	}
+	message := fmt.Sprintf("📅 HyperLiquid%s - %s\n", title, wallet.Name)
+	message += fmt.Sprintf("🕒 %s → %s\n\n", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04"))
+	message += fmt.Sprintf("💼 账户地址: %s\n", shortenAddress(wallet.Address))
+
+	var startValue float64
+	err = db.QueryRow("SELECT account_value FROM account_snapshots WHERE address = ? AND time >= ? ORDER BY time LIMIT 1",
+		strings.ToLower(wallet.Address), start.Unix()).Scan(&startValue)
+	switch {
+	case err == sql.ErrNoRows:
+		message += "💰 期初权益: 暂无数据\n"
+		message += fmt.Sprintf("💰 期末权益: $%.2f\n\n", summary.AccountValue)
+	case err != nil:
+		return "", fmt.Errorf("读取账户快照失败: %v", err)
+	default:
+		change := summary.AccountValue - startValue
+		changePercent := 0.0
+		if startValue != 0 {
+			changePercent = change / startValue * 100
+		}
+		message += fmt.Sprintf("💰 期初权益: $%.2f\n", startValue)
+		message += fmt.Sprintf("💰 期末权益: $%.2f (%+.2f%%, $%+.2f)\n\n", summary.AccountValue, changePercent, change)
+	}
+
+	realized, fees := 0.0, 0.0
+	for _, fill := range fills {
+		closedPnl, _ := strconv.ParseFloat(fill.ClosedPnl, 64)
+		fee, _ := strconv.ParseFloat(fill.Fee, 64)
+		realized += closedPnl
+		fees += fee
+	}
+	message += fmt.Sprintf("📈 已实现盈亏: $%+.2f\n", realized)
+	message += fmt.Sprintf("🧾 手续费: $%.2f\n", fees)
+
+	paid, received := 0.0, 0.0
+	for _, event := range funding {
+		usdc, _ := strconv.ParseFloat(event.Delta.Usdc, 64)
+		if usdc < 0 {
+			paid -= usdc
+		} else {
+			received += usdc
+		}
+	}
+	message += fmt.Sprintf("💸 资金费: 支付 $%.2f / 收取 $%.2f (净 $%+.2f)\n", paid, received, received-paid)
+	if complete {
+		message += fmt.Sprintf("🔢 成交笔数: %d\n", len(fills))
+	} else {
+		message += fmt.Sprintf("🔢 成交笔数: %d+（成交过多，仅统计最早的 %d 笔）\n", len(fills), len(fills))
+	}
+
+	// 最大盈利和亏损按期间内平仓的往返交易计算，已扣手续费
+	var closed []*Trade
+	for _, trade := range trades {
+		if trade.closed() && !trade.CloseTime.After(end) {
+			closed = append(closed, trade)
+		}
+	}
+	stats := computeTradeStats(closed)
+	if stats.Best != nil && stats.Best.netPnl() > 0 {
+		message += fmt.Sprintf("🏆 最大盈利交易: %s\n", formatTradeSummary(stats.Best))
+	}
+	if stats.Worst != nil && stats.Worst.netPnl() < 0 {
+		message += fmt.Sprintf("💔 最大亏损交易: %s\n", formatTradeSummary(stats.Worst))
+	}
+
+	exposure := newAccountSnapshot(positions, summary, end)
+	message += "\n📊 当前敞口:\n"
+	if len(positions) == 0 {
+		return message + "   没有开放的持仓。", nil
+	}
+	message += fmt.Sprintf("   多头 $%.2f / 空头 $%.2f (净 $%+.2f)\n", exposure.LongNotional, exposure.ShortNotional, exposure.netExposure())
+	if summary.AccountValue > 0 {
+		message += fmt.Sprintf("   杠杆: %.2fx\n", exposure.grossExposure()/summary.AccountValue)
+	}
+	for _, view := range positionViews(positions) {
+		message += fmt.Sprintf("   🪙 %s %s %.5f ($%.2f)，盈亏 $%.2f\n", view.Coin, view.Direction, view.Size, view.Value, view.UnrealizedPnl)
+	}
+	return message, nil
+}
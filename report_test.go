@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 发送失败时不更新发送时间，等待 reportRetryDelay 后重试，成功后才保存
+func TestScheduledReportUpdatesLastSentOnlyAfterSuccess(t *testing.T) {
+	setupTestDB(t)
+	fakeInfoServer(t)
+	notifier := registerTestNotifier(t)
+	notifier.fail(errors.New("channel down"))
+	t.Cleanup(func() { reportRetryAt = make(map[string]time.Time) })
+
+	address := "0x6666666666666666666666666666666666666666"
+	lastSent := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet := WalletConfig{Address: address, Name: "测试", ChatID: "1", Channel: testChannel,
+		Report: ReportSchedule{Period: ReportDaily, Minute: 9 * 60, Location: time.UTC, LastSent: lastSent}}
+	key := wallet.ChatID + "_" + address
+	if err := saveSubscriptionToDB(wallet.ChatID, address, wallet.Name); err != nil {
+		t.Fatal(err)
+	}
+	walletMutex.Lock()
+	wallets[key] = wallet
+	walletMutex.Unlock()
+
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	due := claimDueReports(now)
+	if _, exists := due[key]; !exists || len(due) != 1 {
+		t.Fatalf("due = %v, want the daily report", due)
+	}
+	// 发送期间不会被再次取出
+	if len(claimDueReports(now.Add(time.Minute))) != 0 {
+		t.Fatalf("report claimed twice while sending")
+	}
+
+	deliverScheduledReport(key, due[key], now)
+	if got := wallets[key].Report.LastSent; !got.Equal(lastSent) {
+		t.Errorf("LastSent = %v after failed send, want %v", got, lastSent)
+	}
+	if len(claimDueReports(now.Add(time.Minute))) != 0 {
+		t.Fatalf("failed report retried before reportRetryDelay")
+	}
+
+	notifier.fail(nil)
+	retry := now.Add(reportRetryDelay)
+	due = claimDueReports(retry)
+	if _, exists := due[key]; !exists {
+		t.Fatalf("failed report not retried after reportRetryDelay")
+	}
+	deliverScheduledReport(key, due[key], retry)
+	if notifier.count() != 1 {
+		t.Fatalf("got %d reports, want 1", notifier.count())
+	}
+	if got := wallets[key].Report.LastSent; !got.Equal(retry) {
+		t.Errorf("LastSent = %v after successful send, want %v", got, retry)
+	}
+	var saved int64
+	if err := db.QueryRow("SELECT report_last_sent FROM subscriptions WHERE chat_id = ? AND address = ?", wallet.ChatID, address).Scan(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved != retry.Unix() {
+		t.Errorf("saved report_last_sent = %d, want %d", saved, retry.Unix())
+	}
+	if len(claimDueReports(retry.Add(time.Hour))) != 0 {
+		t.Errorf("report due again after it was sent")
+	}
+}
+
+// backgroundRecorder 模拟在后台发送的渠道：Notify 只受理，由测试决定何时送达
+type backgroundRecorder struct {
+	recordingNotifier
+}
+
+func (*backgroundRecorder) sendsInBackground() {}
+
+// 后台渠道受理后，只有确认送达才记录发送时间；被丢弃的报告在 reportRetryDelay 后重试
+func TestScheduledReportWaitsForBackgroundDelivery(t *testing.T) {
+	setupTestDB(t)
+	fakeInfoServer(t)
+	notifier := &backgroundRecorder{}
+	registerNotifier(testChannel, notifier)
+	t.Cleanup(func() {
+		delete(notifiers, testChannel)
+		reportRetryAt = make(map[string]time.Time)
+	})
+
+	address := "0x6666666666666666666666666666666666666666"
+	lastSent := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet := WalletConfig{Address: address, Name: "测试", ChatID: "1", Channel: testChannel,
+		Report: ReportSchedule{Period: ReportDaily, Minute: 9 * 60, Location: time.UTC, LastSent: lastSent}}
+	key := wallet.ChatID + "_" + address
+	if err := saveSubscriptionToDB(wallet.ChatID, address, wallet.Name); err != nil {
+		t.Fatal(err)
+	}
+	walletMutex.Lock()
+	wallets[key] = wallet
+	walletMutex.Unlock()
+
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	due := claimDueReports(now)
+	deliverScheduledReport(key, due[key], now)
+	if notifier.count() != 1 {
+		t.Fatalf("got %d reports, want 1", notifier.count())
+	}
+	if got := wallets[key].Report.LastSent; !got.Equal(lastSent) {
+		t.Fatalf("LastSent = %v before delivery, want %v", got, lastSent)
+	}
+
+	// 第一份报告被发送队列丢弃，没有回调，等待后重新发送
+	retry := now.Add(reportRetryDelay)
+	due = claimDueReports(retry)
+	if _, exists := due[key]; !exists {
+		t.Fatalf("dropped report not retried after reportRetryDelay")
+	}
+	deliverScheduledReport(key, due[key], retry)
+	notifier.mu.Lock()
+	onSent := notifier.notifications[1].OnSent
+	notifier.mu.Unlock()
+	onSent()
+	if got := wallets[key].Report.LastSent; !got.Equal(retry) {
+		t.Errorf("LastSent = %v after delivery, want %v", got, retry)
+	}
+	if len(claimDueReports(retry.Add(reportRetryDelay))) != 0 {
+		t.Errorf("report due again after it was delivered")
+	}
+}
+
+// 最大盈利和亏损按单笔往返交易计算，而不是按币种合计
+func TestPerformanceReportBestAndWorstTrades(t *testing.T) {
+	setupTestDB(t)
+	fakeInfoServer(t)
+
+	address := "0x6666666666666666666666666666666666666666"
+	end := time.Now()
+	trade := func(coin string, closedAgo time.Duration, pnl float64) *Trade {
+		return &Trade{Address: address, Coin: coin, Long: true, OpenTime: end.Add(-closedAgo - time.Hour),
+			CloseTime: end.Add(-closedAgo), MaxSize: 1, RealizedPnl: pnl, Fills: 2}
+	}
+	trades := []*Trade{
+		trade("BTC", 5*time.Hour, 300),
+		trade("BTC", 4*time.Hour, -500),
+		trade("ETH", 3*time.Hour, -100),
+		trade("SOL", 48*time.Hour, 900), // 不在报告期间内
+	}
+	if err := saveTrades(address, trades, FillCursor{Time: end.UnixMilli()}); err != nil {
+		t.Fatal(err)
+	}
+
+	wallet := WalletConfig{Address: address, Name: "测试", ChatID: "1"}
+	message, err := buildPerformanceReport(wallet, ReportSchedule{Period: ReportDaily}, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"🏆 最大盈利交易: BTC 多头 $+300.00", "💔 最大亏损交易: BTC 多头 $-500.00"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("report missing %q:\n%s", want, message)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const defaultSizeChangePercent = 1.0
+
+// AlertSettings 是单个订阅的提醒阈值，nil 表示使用默认值
+type AlertSettings struct {
+	SizeChangePercent   *float64 // 仓位大小变化百分比
+	MinNotionalUsd      *float64 // 仓位变化的最小名义价值
+	PnlChangeUsd        *float64 // 单个仓位未实现盈亏变化金额
+	AccountValuePercent *float64 // 账户价值变化百分比
+}
+
+type alertSetting struct {
+	Key         string
+	Column      string
+	Description string
+	Unit        string
+	field       func(*AlertSettings) **float64
+	defaultText func() string
+}
+
+var alertSettingItems = []alertSetting{
+	{
+		Key: "size", Column: "size_change_pct", Description: "仓位大小变化", Unit: "%",
+		field:       func(s *AlertSettings) **float64 { return &s.SizeChangePercent },
+		defaultText: func() string { return formatThreshold(defaultSizeChangePercent, "%") },
+	},
+	{
+		Key: "notional", Column: "min_notional_usd", Description: "最小变化名义价值", Unit: "USD",
+		field:       func(s *AlertSettings) **float64 { return &s.MinNotionalUsd },
+		defaultText: func() string { return "不限制" },
+	},
+	{
+		Key: "pnl", Column: "pnl_change_usd", Description: "单仓位盈亏变化", Unit: "USD",
+		field:       func(s *AlertSettings) **float64 { return &s.PnlChangeUsd },
+		defaultText: func() string { return "不提醒" },
+	},
+	{
+		Key: "value", Column: "account_value_pct", Description: "账户价值变化", Unit: "%",
+		field: func(s *AlertSettings) **float64 { return &s.AccountValuePercent },
+		defaultText: func() string {
+			if config.AccountValueAlert.Percent <= 0 {
+				return "不提醒"
+			}
+			return formatThreshold(config.AccountValueAlert.Percent, "%")
+		},
+	},
+}
+
+func (s AlertSettings) sizeChangePercent() float64 {
+	if s.SizeChangePercent != nil {
+		return *s.SizeChangePercent
+	}
+	return defaultSizeChangePercent
+}
+
+func (s AlertSettings) minNotionalUsd() float64 {
+	if s.MinNotionalUsd != nil {
+		return *s.MinNotionalUsd
+	}
+	return 0
+}
+
+func (s AlertSettings) pnlChangeUsd() float64 {
+	if s.PnlChangeUsd != nil {
+		return *s.PnlChangeUsd
+	}
+	return 0
+}
+
+// 订阅级别的账户价值阈值只覆盖百分比，绝对金额沿用全局配置
+func (s AlertSettings) accountValueAlert() *ChangeThreshold {
+	if s.AccountValuePercent == nil {
+		return config.AccountValueAlert
+	}
+	return &ChangeThreshold{Percent: *s.AccountValuePercent, Usd: config.AccountValueAlert.Usd}
+}
+
+func migrateSubscriptionSettings(db *sql.DB) error {
+	for _, item := range alertSettingItems {
+		if err := addColumnIfMissing(db, "subscriptions", item.Column, "REAL"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func settingsColumns() string {
+	columns := make([]string, len(alertSettingItems))
+	for i, item := range alertSettingItems {
+		columns[i] = item.Column
+	}
+	return strings.Join(columns, ", ")
+}
+
+// 返回与 settingsColumns 顺序一致的 Scan 目标
+func settingsScanTargets(values []sql.NullFloat64) []any {
+	targets := make([]any, len(values))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	return targets
+}
+
+func settingsFromNullFloats(values []sql.NullFloat64) AlertSettings {
+	var settings AlertSettings
+	for i, item := range alertSettingItems {
+		if values[i].Valid {
+			value := values[i].Float64
+			*item.field(&settings) = &value
+		}
+	}
+	return settings
+}
+
+func saveSubscriptionSettingToDB(chatID, address, column string, value *float64) error {
+	_, err := db.Exec(fmt.Sprintf("UPDATE subscriptions SET %s = ? WHERE chat_id = ? AND address = ?", column), value, chatID, address)
+	return err
+}
+
+func handleSettingsCommand(chatID, msgText string) {
+	parts := strings.Fields(msgText)
+	if len(parts) < 2 {
+		sendMessage(chatID, settingsUsage())
+		return
+	}
+	address := parts[1]
+	if !isValidHexadecimal(address) {
+		sendMessage(chatID, "无效的地址格式。")
+		return
+	}
+
+	switch len(parts) {
+	case 2:
+		showSettings(chatID, address)
+	case 3:
+		if parts[2] != "reset" {
+			sendMessage(chatID, settingsUsage())
+			return
+		}
+		for _, item := range alertSettingItems {
+			if !updateSetting(chatID, address, item, nil) {
+				return
+			}
+		}
+		showSettings(chatID, address)
+	case 4:
+		var item *alertSetting
+		for i := range alertSettingItems {
+			if alertSettingItems[i].Key == parts[2] {
+				item = &alertSettingItems[i]
+			}
+		}
+		if item == nil {
+			sendMessage(chatID, settingsUsage())
+			return
+		}
+
+		var value *float64
+		if parts[3] != "default" {
+			parsed, err := strconv.ParseFloat(parts[3], 64)
+			if err != nil || parsed < 0 {
+				sendMessage(chatID, "阈值必须是非负数字，或使用 default 恢复默认值。")
+				return
+			}
+			value = &parsed
+		}
+		if updateSetting(chatID, address, *item, value) {
+			showSettings(chatID, address)
+		}
+	default:
+		sendMessage(chatID, settingsUsage())
+	}
+}
+
+func updateSetting(chatID, address string, item alertSetting, value *float64) bool {
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+
+	key := chatID + "_" + address
+	wallet, exists := wallets[key]
+	if !exists {
+		sendMessage(chatID, fmt.Sprintf("地址 %s 未被订阅", shortenAddress(address)))
+		return false
+	}
+
+	if err := saveSubscriptionSettingToDB(chatID, address, item.Column, value); err != nil {
+		log.Printf("保存订阅设置失败: %v", err)
+		sendMessage(chatID, "保存设置失败，请稍后重试。")
+		return false
+	}
+	*item.field(&wallet.Settings) = value
+	wallets[key] = wallet
+	return true
+}
+
+func showSettings(chatID, address string) {
+	walletMutex.Lock()
+	wallet, exists := wallets[chatID+"_"+address]
+	walletMutex.Unlock()
+	if !exists {
+		sendMessage(chatID, fmt.Sprintf("地址 %s 未被订阅", shortenAddress(address)))
+		return
+	}
+
+	message := fmt.Sprintf("⚙️ 提醒设置 - %s (%s)\n\n", wallet.Name, shortenAddress(wallet.Address))
+	for _, item := range alertSettingItems {
+		value := *item.field(&wallet.Settings)
+		text := item.defaultText() + " (默认)"
+		if value != nil {
+			text = formatThreshold(*value, item.Unit)
+		}
+		message += fmt.Sprintf("• %s [%s]: %s\n", item.Description, item.Key, text)
+	}
+	message += "\n" + settingsUsage()
+	sendMessage(chatID, message)
+}
+
+func settingsUsage() string {
+	return "用法:\n/settings <地址> - 查看提醒设置\n/settings <地址> <size|notional|pnl|value> <数值|default> - 修改阈值\n/settings <地址> reset - 全部恢复默认"
+}
+
+func formatThreshold(value float64, unit string) string {
+	if unit == "%" {
+		return fmt.Sprintf("%g%%", value)
+	}
+	return fmt.Sprintf("%g %s", value, unit)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+func handleStatusCommand(chatID, msgText string) {
+	query := strings.TrimSpace(strings.TrimPrefix(msgText, "/status"))
+	targets, ok := statusTargets(chatID, query)
+	if !ok {
+		sendMessage(chatID, fmt.Sprintf("未找到名称或地址为 %s 的订阅。\n用法: /status [地址|名称|#标签]", query))
+		return
+	}
+	if len(targets) == 0 {
+		sendMessage(chatID, "您尚未订阅任何地址。")
+		return
+	}
+
+	go func() {
+		prices := getMarkPrices()
+		for _, wallet := range targets {
+			positions, summary, err := fetchPositions(wallet.Address)
+			if err != nil {
+				log.Printf("获取 %s 状态失败: %v", wallet.Address, err)
+				sendMessage(chatID, fmt.Sprintf("获取地址 %s 状态失败: %v", shortenAddress(wallet.Address), err))
+				continue
+			}
+			sendMessage(chatID, formatStatusMessage(wallet, positions, summary, prices, time.Now()))
+		}
+	}()
+}
+
+// 查询为空时返回该聊天的全部订阅，否则按地址、名称或标签匹配，
+// 未订阅的地址仅对已授权用户开放查询
+func statusTargets(chatID, query string) ([]WalletConfig, bool) {
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+
+	var targets []WalletConfig
+	seen := make(map[string]bool)
+	for _, wallet := range matchWallets(chatID, query) {
+		address := strings.ToLower(wallet.Address)
+		if !seen[address] {
+			seen[address] = true
+			targets = append(targets, wallet)
+		}
+	}
+	if query == "" || len(targets) > 0 {
+		return targets, true
+	}
+	if isValidHexadecimal(query) && authorizedUsers[chatID] {
+		return []WalletConfig{{Address: query, Name: shortenAddress(query), ChatID: chatID}}, true
+	}
+	return nil, false
+}
+
+func formatStatusMessage(wallet WalletConfig, positions map[string]hyperliquid.Position, summary AccountSummary, prices map[string]float64, now time.Time) string {
+	timeStamp := now.Format("2006-01-02 15:04:05")
+	message := fmt.Sprintf("📊 HyperLiquid账户状态 - %s (%s)\n\n", wallet.Name, timeStamp)
+	message += fmt.Sprintf("💼 账户地址: %s\n", shortenAddress(wallet.Address))
+	message += fmt.Sprintf("💰 账户价值: $%.2f\n", summary.AccountValue)
+	message += fmt.Sprintf("💵 可提取金额: $%.2f\n", summary.Withdrawable)
+	usage := 0.0
+	if summary.AccountValue > 0 {
+		usage = summary.TotalMarginUsed / summary.AccountValue * 100
+	}
+	message += fmt.Sprintf("💸 已用保证金: $%.2f (占账户 %.2f%%)\n\n", summary.TotalMarginUsed, usage)
+
+	if len(positions) == 0 {
+		return message + "没有找到开放的持仓。"
+	}
+
+	totalFunding := 0.0
+	message += "📈 当前持仓:\n\n"
+	for _, position := range sortedPositions(positions) {
+		view := newPositionView(position)
+		message += fmt.Sprintf("🪙 %s (%s)\n", view.Coin, view.Direction)
+		message += fmt.Sprintf("   仓位大小: %.5f ($%.2f)\n", view.Size, view.Value)
+		message += fmt.Sprintf("   入场价格: $%.2f\n", view.EntryPx)
+		markPx, exists := prices[position.Coin]
+		if !exists {
+			markPx = positionPrice(position)
+		}
+		message += fmt.Sprintf("   标记价格: $%.2f\n", markPx)
+		message += fmt.Sprintf("   杠杆: %dx (%s)\n", view.Leverage, marginModeName(view.LeverageType))
+		pnlEmoji := "🔴"
+		if view.UnrealizedPnl >= 0 {
+			pnlEmoji = "🟢"
+		}
+		message += fmt.Sprintf("   %s 盈亏: $%.2f (%.2f%%)\n", pnlEmoji, view.UnrealizedPnl, view.RoiPercent)
+		if gap, ok := liquidationDistance(position, markPx); ok {
+			message += fmt.Sprintf("   ⚠️ 强平价格: $%.2f (距离 %.2f%%)\n", view.LiquidationPx, gap)
+		} else {
+			message += "   ⚠️ 强平价格: 无\n"
+		}
+		message += fmt.Sprintf("   已用保证金: $%.2f\n", view.MarginUsed)
+		sinceOpen, _ := strconv.ParseFloat(position.CumFunding.SinceOpen, 64)
+		allTime, _ := strconv.ParseFloat(position.CumFunding.AllTime, 64)
+		totalFunding += allTime
+		message += fmt.Sprintf("   资金费: 开仓以来 $%.2f，累计 $%.2f\n\n", sinceOpen, allTime)
+	}
+	// Hyperliquid 的累计资金费以支付为正
+	message += fmt.Sprintf("🧾 持仓累计资金费: $%.2f（正数为支付，负数为收取）", totalFunding)
+	return message
+}
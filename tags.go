@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 标签以 # 开头，由字母、数字、下划线和连字符组成，统一转为小写保存
+var tagPattern = regexp.MustCompile(`^#[\p{L}\p{N}_-]{1,32}$`)
+
+func normalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(tag)
+	return tag, tagPattern.MatchString(tag)
+}
+
+func (w WalletConfig) hasTag(tag string) bool {
+	return containsString(w.Tags, tag)
+}
+
+// 数据库中的标签以空格分隔
+func parseTags(column string) []string {
+	return strings.Fields(column)
+}
+
+func formatTags(tags []string) string {
+	return strings.Join(tags, " ")
+}
+
+func saveSubscriptionTagsToDB(chatID, address string, tags []string) error {
+	_, err := db.Exec("UPDATE subscriptions SET tags = ? WHERE chat_id = ? AND address = ?", formatTags(tags), chatID, address)
+	return err
+}
+
+// 按选择器匹配某个聊天的订阅：为空时返回全部，#开头按标签匹配，否则按地址或名称匹配（不区分大小写）。
+// 调用方需持有 walletMutex
+func matchWallets(chatID, selector string) []WalletConfig {
+	var matched []WalletConfig
+	for _, wallet := range chatWallets(chatID) {
+		switch {
+		case selector == "":
+		case strings.HasPrefix(selector, "#"):
+			if !wallet.hasTag(strings.ToLower(selector)) {
+				continue
+			}
+		case !strings.EqualFold(wallet.Address, selector) && !strings.EqualFold(wallet.Name, selector):
+			continue
+		}
+		matched = append(matched, wallet)
+	}
+	return matched
+}
+
+func handleRenameCommand(chatID, msgText string) {
+	parts := strings.SplitN(msgText, " ", 3)
+	if len(parts) < 3 || strings.TrimSpace(parts[2]) == "" {
+		sendMessage(chatID, "用法: /rename <地址> <新名称>")
+		return
+	}
+	if !isValidHexadecimal(parts[1]) {
+		sendMessage(chatID, "无效的地址格式。")
+		return
+	}
+	renameWallet(chatID, parts[1], strings.TrimSpace(parts[2]))
+}
+
+// /tag 和 /untag：给匹配的订阅添加或移除标签
+func handleTagCommand(chatID, msgText string, add bool) {
+	usage := "用法: /tag <地址|名称|#标签> #标签1 [#标签2 ...]"
+	if !add {
+		usage = "用法: /untag <地址|名称|#标签> #标签1 [#标签2 ...]"
+	}
+	parts := strings.Fields(msgText)
+	if len(parts) < 3 {
+		sendMessage(chatID, usage)
+		return
+	}
+	var tags []string
+	for _, part := range parts[2:] {
+		tag, ok := normalizeTag(part)
+		if !ok {
+			sendMessage(chatID, fmt.Sprintf("无效的标签 %s，标签需以#开头，只能包含字母、数字、下划线和连字符。", part))
+			return
+		}
+		tags = append(tags, tag)
+	}
+
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+
+	matched := matchWallets(chatID, parts[1])
+	if len(matched) == 0 {
+		sendMessage(chatID, fmt.Sprintf("未找到匹配 %s 的订阅", parts[1]))
+		return
+	}
+	for _, wallet := range matched {
+		updated := make([]string, 0, len(wallet.Tags)+len(tags))
+		for _, existing := range wallet.Tags {
+			if !containsString(tags, existing) {
+				updated = append(updated, existing)
+			}
+		}
+		if add {
+			updated = append(updated, tags...)
+		}
+		sort.Strings(updated)
+		if err := saveSubscriptionTagsToDB(chatID, wallet.Address, updated); err != nil {
+			log.Printf("保存标签失败: %v", err)
+			sendMessage(chatID, "保存标签失败，请稍后重试。")
+			return
+		}
+		wallet.Tags = updated
+		wallets[chatID+"_"+wallet.Address] = wallet
+	}
+
+	action := "添加"
+	if !add {
+		action = "移除"
+	}
+	sendMessage(chatID, fmt.Sprintf("已为 %d 个订阅%s标签 %s", len(matched), action, formatTags(tags)))
+}
+
+// /tags：列出聊天内使用的标签及对应的订阅数
+func handleTagsCommand(chatID string) {
+	walletMutex.Lock()
+	counts := make(map[string]int)
+	for _, wallet := range chatWallets(chatID) {
+		for _, tag := range wallet.Tags {
+			counts[tag]++
+		}
+	}
+	walletMutex.Unlock()
+
+	if len(counts) == 0 {
+		sendMessage(chatID, "尚未使用任何标签。\n用法: /tag <地址|名称> #标签")
+		return
+	}
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	message := "🏷️ 标签列表:\n\n"
+	for _, tag := range tags {
+		message += fmt.Sprintf("%s - %d 个订阅\n", tag, counts[tag])
+	}
+	message += "\n可在 /list、/status、/mute、/unmute 中使用标签，例如 /status #whales"
+	sendMessage(chatID, message)
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	telegramMaxMessageLength = 4096 // 按 UTF-16 编码单元计算
+	telegramGlobalInterval   = time.Second / 30
+	telegramPrivateInterval  = time.Second
+	telegramGroupInterval    = 3 * time.Second // 群组每分钟最多 20 条
+	telegramCoalesceWindow   = 2 * time.Second
+	telegramMaxQueuedPerChat = 200
+	telegramMaxAttempts      = 3
+)
+
+var telegramOutbox *TelegramOutbox
+
+type outboundMessage struct {
+	text      string
+	chattable tgbotapi.Chattable // 不为 nil 时原样发送（图片、编辑、带键盘的消息），不拆分也不合并
+	onSent    func(tgbotapi.Message)
+	coalesce  bool
+	readyAt   time.Time
+	attempts  int
+	sending   bool
+}
+
+// TelegramOutbox 是 Telegram 的发送队列：每个聊天按顺序发送，并同时遵守全局和单个聊天的频率限制。
+//
+// 可合并的提醒会先等待 telegramCoalesceWindow，窗口内同一聊天的后续提醒合并为一条消息；
+// 超长消息按行拆分；遇到 429 时按 retry_after 暂停该聊天。
+// 所有发往聊天的请求都应经过发送队列，否则会绕过频率限制。
+type TelegramOutbox struct {
+	mu       sync.Mutex
+	queues   map[string][]*outboundMessage
+	nextSend map[string]time.Time
+	wake     chan struct{}
+	send     func(tgbotapi.Chattable) (tgbotapi.Message, error)
+}
+
+func NewTelegramOutbox(send func(tgbotapi.Chattable) (tgbotapi.Message, error)) *TelegramOutbox {
+	return &TelegramOutbox{
+		queues:   make(map[string][]*outboundMessage),
+		nextSend: make(map[string]time.Time),
+		wake:     make(chan struct{}, 1),
+		send:     send,
+	}
+}
+
+// Enqueue 把文本放入 chatID 的发送队列，全部拆分后的消息发送成功后调用 onSent（可以为 nil）。
+// 发送失败被放弃的消息不会调用 onSent
+func (o *TelegramOutbox) Enqueue(chatID, text string, coalesce bool, onSent func()) error {
+	chunks := splitMessage(text, telegramMaxMessageLength)
+	now := time.Now()
+
+	o.mu.Lock()
+	queue := o.queues[chatID]
+	if len(queue)+len(chunks) > telegramMaxQueuedPerChat {
+		o.mu.Unlock()
+		return fmt.Errorf("聊天 %s 的发送队列已满", chatID)
+	}
+	for i, chunk := range chunks {
+		// 按顺序发送，最后一段发送成功时前面的部分都已送达
+		var chunkSent func(tgbotapi.Message)
+		if onSent != nil && i == len(chunks)-1 {
+			chunkSent = func(tgbotapi.Message) { onSent() }
+		}
+		if coalesce && len(queue) > 0 {
+			tail := queue[len(queue)-1]
+			merged := tail.text + "\n\n" + chunk
+			if tail.coalesce && !tail.sending && utf16Len(merged) <= telegramMaxMessageLength {
+				tail.text = merged
+				tail.onSent = chainOnSent(tail.onSent, chunkSent)
+				continue
+			}
+		}
+		message := &outboundMessage{text: chunk, coalesce: coalesce, readyAt: now, onSent: chunkSent}
+		if coalesce {
+			message.readyAt = now.Add(telegramCoalesceWindow)
+		}
+		queue = append(queue, message)
+	}
+	o.queues[chatID] = queue
+	o.mu.Unlock()
+
+	o.notify()
+	return nil
+}
+
+// EnqueueChattable 把图片、消息编辑等请求放入 chatID 的发送队列，发送成功后调用 onSent（可以为 nil）
+func (o *TelegramOutbox) EnqueueChattable(chatID string, chattable tgbotapi.Chattable, onSent func(tgbotapi.Message)) error {
+	o.mu.Lock()
+	queue := o.queues[chatID]
+	if len(queue) >= telegramMaxQueuedPerChat {
+		o.mu.Unlock()
+		return fmt.Errorf("聊天 %s 的发送队列已满", chatID)
+	}
+	o.queues[chatID] = append(queue, &outboundMessage{chattable: chattable, onSent: onSent, readyAt: time.Now()})
+	o.mu.Unlock()
+
+	o.notify()
+	return nil
+}
+
+// 合并后的消息发送成功时，依次调用被合并的各条提醒的回调
+func chainOnSent(first, second func(tgbotapi.Message)) func(tgbotapi.Message) {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(message tgbotapi.Message) {
+		first(message)
+		second(message)
+	}
+}
+
+func (o *TelegramOutbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *TelegramOutbox) Run() {
+	for {
+		o.mu.Lock()
+		chatID, message, wait := o.next(time.Now())
+		o.mu.Unlock()
+
+		if message == nil {
+			select {
+			case <-o.wake:
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		chattable := message.chattable
+		if chattable == nil {
+			chattable = tgbotapi.NewMessageToChannel(chatID, message.text)
+		}
+		sent, err := o.send(chattable)
+		if err == nil && message.onSent != nil {
+			message.onSent(sent)
+		}
+		o.finish(chatID, message, err, time.Now())
+		time.Sleep(telegramGlobalInterval)
+	}
+}
+
+// 选出最早可以发送的聊天队首消息；没有可发送的消息时返回需要等待的时间。调用方需持有 mu
+func (o *TelegramOutbox) next(now time.Time) (string, *outboundMessage, time.Duration) {
+	var bestChat string
+	var bestReady time.Time
+	for chatID, queue := range o.queues {
+		if len(queue) == 0 {
+			continue
+		}
+		ready := queue[0].readyAt
+		if o.nextSend[chatID].After(ready) {
+			ready = o.nextSend[chatID]
+		}
+		if bestChat == "" || ready.Before(bestReady) {
+			bestChat, bestReady = chatID, ready
+		}
+	}
+	if bestChat == "" {
+		return "", nil, time.Minute
+	}
+	if bestReady.After(now) {
+		return "", nil, bestReady.Sub(now)
+	}
+	message := o.queues[bestChat][0]
+	message.sending = true
+	return bestChat, message, 0
+}
+
+func (o *TelegramOutbox) finish(chatID string, message *outboundMessage, err error, now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	message.sending = false
+	o.nextSend[chatID] = now.Add(telegramChatInterval(chatID))
+	if err != nil {
+		var apiErr *tgbotapi.Error
+		switch {
+		case errors.As(err, &apiErr) && apiErr.RetryAfter > 0:
+			log.Printf("Telegram限流 (ChatID: %s)，%d秒后重试", chatID, apiErr.RetryAfter)
+			o.nextSend[chatID] = now.Add(time.Duration(apiErr.RetryAfter) * time.Second)
+			return
+		case errors.As(err, &apiErr) && (apiErr.Code == 400 || apiErr.Code == 403):
+			// 聊天不存在、机器人被移出或被屏蔽，重试没有意义
+			log.Printf("发送Telegram消息失败 (ChatID: %s): %v", chatID, err)
+		default:
+			message.attempts++
+			if message.attempts < telegramMaxAttempts {
+				log.Printf("发送Telegram消息失败 (ChatID: %s)，第%d次重试: %v", chatID, message.attempts, err)
+				o.nextSend[chatID] = now.Add(time.Duration(message.attempts) * 2 * time.Second)
+				return
+			}
+			log.Printf("发送Telegram消息失败 (ChatID: %s)，已放弃: %v", chatID, err)
+		}
+	}
+
+	queue := o.queues[chatID][1:]
+	if len(queue) == 0 {
+		delete(o.queues, chatID)
+		return
+	}
+	o.queues[chatID] = queue
+}
+
+// 群组和频道的 Chat ID 为负数
+func telegramChatInterval(chatID string) time.Duration {
+	if strings.HasPrefix(chatID, "-") {
+		return telegramGroupInterval
+	}
+	return telegramPrivateInterval
+}
+
+// 按行拆分超长消息，单行超长时再按字符拆分
+func splitMessage(text string, limit int) []string {
+	if utf16Len(text) <= limit {
+		return []string{text}
+	}
+	var chunks []string
+	current := ""
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for utf16Len(line) > limit {
+			if current != "" {
+				chunks = append(chunks, current)
+				current = ""
+			}
+			head := truncateUTF16(line, limit)
+			chunks = append(chunks, head)
+			line = line[len(head):]
+		}
+		if current != "" && utf16Len(current)+utf16Len(line) > limit {
+			chunks = append(chunks, current)
+			current = ""
+		}
+		current += line
+	}
+	if strings.TrimSpace(current) != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+func utf16Len(text string) int {
+	return len(utf16.Encode([]rune(text)))
+}
+
+// 返回不超过 limit 个 UTF-16 编码单元的最长前缀
+func truncateUTF16(text string, limit int) string {
+	length := 0
+	for i, r := range text {
+		length += utf16.RuneLen(r)
+		if length > limit {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// sendMessage 把消息放入发送队列，由 TelegramOutbox 按频率限制发送。
+// 发送在后台进行，失败由发送队列记录日志；这里只记录入队失败
+func sendMessage(chatID, message string) {
+	if err := telegramOutbox.Enqueue(chatID, message, false, nil); err != nil {
+		log.Printf("发送消息失败 (ChatID: %s): %v", chatID, err)
+	}
+}
+
+// sendChattable 把图片、消息编辑等请求放入发送队列
+func sendChattable(chatID string, chattable tgbotapi.Chattable, onSent func(tgbotapi.Message)) {
+	if err := telegramOutbox.EnqueueChattable(chatID, chattable, onSent); err != nil {
+		log.Printf("发送消息失败 (ChatID: %s): %v", chatID, err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// 文本、图片和消息编辑都经过同一个队列，按入队顺序发送，限流后重试
+func TestTelegramOutboxSendsChattables(t *testing.T) {
+	var mu sync.Mutex
+	var sent []tgbotapi.Chattable
+	limited := false
+	outbox := NewTelegramOutbox(func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, isPhoto := c.(tgbotapi.PhotoConfig); isPhoto && !limited {
+			limited = true
+			return tgbotapi.Message{}, &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1}}
+		}
+		sent = append(sent, c)
+		return tgbotapi.Message{MessageID: len(sent)}, nil
+	})
+	go outbox.Run()
+
+	promptID := make(chan int, 1)
+	outbox.Enqueue("1", "hello", false, nil)
+	outbox.EnqueueChattable("1", tgbotapi.NewPhoto(1, tgbotapi.FileBytes{Name: "chart.png", Bytes: []byte("png")}), nil)
+	outbox.EnqueueChattable("1", tgbotapi.NewEditMessageText(1, 5, "edited"), func(message tgbotapi.Message) {
+		promptID <- message.MessageID
+	})
+
+	select {
+	case id := <-promptID:
+		if id != 3 {
+			t.Errorf("onSent got message %d, want 3", id)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("queued messages were not sent")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 3 {
+		t.Fatalf("sent %d messages, want 3", len(sent))
+	}
+	if msg, ok := sent[0].(tgbotapi.MessageConfig); !ok || msg.Text != "hello" || msg.ChannelUsername != "1" {
+		t.Errorf("first message = %#v", sent[0])
+	}
+	if _, ok := sent[1].(tgbotapi.PhotoConfig); !ok {
+		t.Errorf("second message = %T, want photo retried after 429", sent[1])
+	}
+	if edit, ok := sent[2].(tgbotapi.EditMessageTextConfig); !ok || edit.Text != "edited" {
+		t.Errorf("third message = %#v", sent[2])
+	}
+}
+
+func TestTelegramOutboxQueueFull(t *testing.T) {
+	outbox := NewTelegramOutbox(func(tgbotapi.Chattable) (tgbotapi.Message, error) { return tgbotapi.Message{}, nil })
+	for i := 0; i < telegramMaxQueuedPerChat; i++ {
+		if err := outbox.EnqueueChattable("1", tgbotapi.NewEditMessageText(1, i, "x"), nil); err != nil {
+			t.Fatalf("enqueue %d: %v", i, err)
+		}
+	}
+	if err := outbox.EnqueueChattable("1", tgbotapi.NewEditMessageText(1, 0, "x"), nil); err == nil {
+		t.Error("expected an error when the queue is full")
+	}
+	if err := outbox.Enqueue("1", "text", false, nil); err == nil {
+		t.Error("expected an error when the queue is full")
+	}
+}
+
+// 合并发送的提醒在送达后各自回调；被放弃的消息不回调
+func TestTelegramOutboxOnSent(t *testing.T) {
+	outbox := NewTelegramOutbox(func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+		if msg := c.(tgbotapi.MessageConfig); msg.ChannelUsername == "2" {
+			return tgbotapi.Message{}, &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}
+		}
+		return tgbotapi.Message{}, nil
+	})
+	go outbox.Run()
+
+	sent := make(chan string, 3)
+	outbox.Enqueue("1", "first", true, func() { sent <- "first" })
+	outbox.Enqueue("1", "second", true, func() { sent <- "second" })
+	outbox.Enqueue("2", "blocked", true, func() { sent <- "blocked" })
+
+	got := make(map[string]bool)
+	timeout := time.After(10 * time.Second)
+	for len(got) < 2 {
+		select {
+		case name := <-sent:
+			got[name] = true
+		case <-timeout:
+			t.Fatalf("onSent called for %v, want first and second", got)
+		}
+	}
+	select {
+	case name := <-sent:
+		t.Errorf("onSent called for %q", name)
+	case <-time.After(500 * time.Millisecond):
+	}
+	if got["blocked"] {
+		t.Error("onSent called for a message that was dropped")
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+const (
+	tradeSyncMaxPages = 10
+	sizeEpsilon       = 1e-9
+)
+
+var tradeMutex sync.Mutex
+
+// Trade 是一笔完整的往返交易：从空仓开仓，经过加仓和减仓，直到回到空仓或反手
+type Trade struct {
+	Address       string
+	Coin          string
+	Long          bool
+	OpenTime      time.Time
+	CloseTime     time.Time // 零值表示仍未平仓
+	MaxSize       float64
+	EntrySize     float64
+	EntryNotional float64
+	ExitSize      float64
+	ExitNotional  float64
+	RealizedPnl   float64
+	Fees          float64
+	Fills         int
+}
+
+func (t *Trade) closed() bool {
+	return !t.CloseTime.IsZero()
+}
+
+func (t *Trade) netPnl() float64 {
+	return t.RealizedPnl - t.Fees
+}
+
+func (t *Trade) holdTime() time.Duration {
+	return t.CloseTime.Sub(t.OpenTime)
+}
+
+// tradeBuilder 按成交顺序重建每个币种的往返交易
+type tradeBuilder struct {
+	address string
+	open    map[string]*Trade
+	touched []*Trade
+}
+
+func newTradeBuilder(address string, open []*Trade) *tradeBuilder {
+	b := &tradeBuilder{address: address, open: make(map[string]*Trade)}
+	for _, trade := range open {
+		b.open[trade.Coin] = trade
+	}
+	return b
+}
+
+func (b *tradeBuilder) touch(trade *Trade) {
+	for _, existing := range b.touched {
+		if existing == trade {
+			return
+		}
+	}
+	b.touched = append(b.touched, trade)
+}
+
+func (b *tradeBuilder) apply(fill hyperliquid.Fill) {
+	// 现货成交没有仓位概念
+	if fill.Dir == "Buy" || fill.Dir == "Sell" || strings.HasPrefix(fill.Coin, "@") {
+		return
+	}
+	before, err := strconv.ParseFloat(fill.StartPosition, 64)
+	if err != nil {
+		return
+	}
+	size, _ := strconv.ParseFloat(fill.Sz, 64)
+	px, _ := strconv.ParseFloat(fill.Px, 64)
+	closedPnl, _ := strconv.ParseFloat(fill.ClosedPnl, 64)
+	fee, _ := strconv.ParseFloat(fill.Fee, 64)
+	after := before + size
+	if fill.Side == "A" {
+		after = before - size
+	}
+	if math.Abs(after) < sizeEpsilon {
+		after = 0
+	}
+	fillTime := time.UnixMilli(fill.Time)
+
+	trade := b.open[fill.Coin]
+	if trade == nil {
+		// 没有记录的持仓（例如历史成交不完整）无法还原，等回到空仓或反手后再开始
+		if math.Abs(before) >= sizeEpsilon && (after == 0 || (before > 0) == (after > 0)) {
+			return
+		}
+		b.openTrade(fill.Coin, after, px, fillTime, fee*math.Abs(after)/size)
+		return
+	}
+
+	trade.Fills++
+	trade.RealizedPnl += closedPnl
+	switch {
+	case after != 0 && (after > 0) == trade.Long && math.Abs(after) > math.Abs(before):
+		// 加仓
+		trade.EntrySize += size
+		trade.EntryNotional += size * px
+		trade.MaxSize = math.Max(trade.MaxSize, math.Abs(after))
+		trade.Fees += fee
+	case after != 0 && (after > 0) == trade.Long:
+		// 减仓
+		trade.ExitSize += size
+		trade.ExitNotional += size * px
+		trade.Fees += fee
+	default:
+		// 平仓或反手，反手时手续费按数量拆分到新交易
+		closing := math.Abs(before)
+		trade.ExitSize += closing
+		trade.ExitNotional += closing * px
+		trade.Fees += fee * closing / size
+		trade.CloseTime = fillTime
+		delete(b.open, fill.Coin)
+		b.touch(trade)
+		if after != 0 {
+			b.openTrade(fill.Coin, after, px, fillTime, fee*math.Abs(after)/size)
+		}
+		return
+	}
+	b.touch(trade)
+}
+
+func (b *tradeBuilder) openTrade(coin string, position, px float64, openTime time.Time, fee float64) {
+	size := math.Abs(position)
+	trade := &Trade{
+		Address:       b.address,
+		Coin:          coin,
+		Long:          position > 0,
+		OpenTime:      openTime,
+		MaxSize:       size,
+		EntrySize:     size,
+		EntryNotional: size * px,
+		Fees:          fee,
+		Fills:         1,
+	}
+	b.open[coin] = trade
+	b.touch(trade)
+}
+
+func loadTradeCursor(address string) (FillCursor, error) {
+	var cursor FillCursor
+	var tids string
+	err := db.QueryRow("SELECT last_fill_time, last_tids FROM trade_cursors WHERE address = ?", address).Scan(&cursor.Time, &tids)
+	if err == sql.ErrNoRows {
+		return cursor, nil
+	}
+	cursor.Tids = parseTids(tids)
+	return cursor, err
+}
+
+func saveTrades(address string, trades []*Trade, cursor FillCursor) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, trade := range trades {
+		var closeTime int64
+		if trade.closed() {
+			closeTime = trade.CloseTime.UnixMilli()
+		}
+		direction := "short"
+		if trade.Long {
+			direction = "long"
+		}
+		_, err := tx.Exec(`
+            INSERT INTO trades (address, coin, direction, open_time, close_time, max_size, entry_size, entry_notional,
+                exit_size, exit_notional, realized_pnl, fees, fills)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+            ON CONFLICT(address, coin, open_time) DO UPDATE SET
+                close_time = excluded.close_time, max_size = excluded.max_size, entry_size = excluded.entry_size,
+                entry_notional = excluded.entry_notional, exit_size = excluded.exit_size, exit_notional = excluded.exit_notional,
+                realized_pnl = excluded.realized_pnl, fees = excluded.fees, fills = excluded.fills
+        `, address, trade.Coin, direction, trade.OpenTime.UnixMilli(), closeTime, trade.MaxSize, trade.EntrySize, trade.EntryNotional,
+			trade.ExitSize, trade.ExitNotional, trade.RealizedPnl, trade.Fees, trade.Fills)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO trade_cursors (address, last_fill_time, last_tids) VALUES (?, ?, ?)",
+		address, cursor.Time, formatTids(cursor.Tids)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// openOnly 为 true 时只加载未平仓的交易；since 不为零时加载此后平仓的交易和所有未平仓的交易
+func loadTrades(address string, openOnly bool, since time.Time) ([]*Trade, error) {
+	query := `
+        SELECT coin, direction, open_time, close_time, max_size, entry_size, entry_notional,
+            exit_size, exit_notional, realized_pnl, fees, fills
+        FROM trades WHERE address = ?`
+	args := []any{address}
+	switch {
+	case openOnly:
+		query += " AND close_time = 0"
+	case !since.IsZero():
+		query += " AND (close_time = 0 OR close_time >= ?)"
+		args = append(args, since.UnixMilli())
+	}
+	rows, err := db.Query(query+" ORDER BY close_time, open_time", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trades []*Trade
+	for rows.Next() {
+		trade := &Trade{Address: address}
+		var direction string
+		var openTime, closeTime int64
+		if err := rows.Scan(&trade.Coin, &direction, &openTime, &closeTime, &trade.MaxSize, &trade.EntrySize, &trade.EntryNotional,
+			&trade.ExitSize, &trade.ExitNotional, &trade.RealizedPnl, &trade.Fees, &trade.Fills); err != nil {
+			return nil, err
+		}
+		trade.Long = direction == "long"
+		trade.OpenTime = time.UnixMilli(openTime)
+		if closeTime > 0 {
+			trade.CloseTime = time.UnixMilli(closeTime)
+		}
+		trades = append(trades, trade)
+	}
+	return trades, rows.Err()
+}
+
+// 从上次游标开始逐页拉取成交并更新交易记录，每页处理完即保存进度，
+// 每次最多拉取 tradeSyncMaxPages 页，剩余的留到下次
+func syncTrades(address string) error {
+	address = strings.ToLower(address)
+	tradeMutex.Lock()
+	defer tradeMutex.Unlock()
+
+	cursor, err := loadTradeCursor(address)
+	if err != nil {
+		return fmt.Errorf("读取交易游标失败: %v", err)
+	}
+	open, err := loadTrades(address, true, time.Time{})
+	if err != nil {
+		return fmt.Errorf("读取未平仓交易失败: %v", err)
+	}
+	builder := newTradeBuilder(address, open)
+
+	for page := 0; page < tradeSyncMaxPages; page++ {
+		fills, next, complete, err := fetchFillsAfter(address, cursor, 0, 1)
+		if err != nil {
+			return err
+		}
+		for _, fill := range fills {
+			builder.apply(fill)
+		}
+		cursor = next
+		if err := saveTrades(address, builder.touched, cursor); err != nil {
+			return fmt.Errorf("保存交易记录失败: %v", err)
+		}
+		builder.touched = nil
+		if complete {
+			break
+		}
+	}
+	return nil
+}
+
+// 每小时同步一次所有订阅地址的交易，避免早期成交超出接口的保留范围
+func runTradeSync() {
+	for {
+		for address := range subscribersByAddress() {
+			if err := syncTrades(address); err != nil {
+				log.Printf("同步 %s 交易记录失败: %v", address, err)
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+type tradeStats struct {
+	Trades       int
+	Wins         int
+	NetPnl       float64
+	GrossProfit  float64
+	GrossLoss    float64
+	AvgHold      time.Duration
+	AvgR         float64 // 每笔平均净盈亏除以亏损交易的平均亏损，即以平均亏损为 1R
+	HasR         bool
+	MaxDrawdown  float64 // 已平仓交易累计盈亏曲线的最大回撤（美元）
+	Best         *Trade
+	Worst        *Trade
+	LongTrades   int
+	ShortTrades  int
+	OpenTrades   int
+	ProfitFactor float64 // 无亏损交易时为 +Inf
+}
+
+func computeTradeStats(trades []*Trade) tradeStats {
+	var stats tradeStats
+	var totalHold time.Duration
+	equity, peak := 0.0, 0.0
+	for _, trade := range trades {
+		if !trade.closed() {
+			stats.OpenTrades++
+			continue
+		}
+		pnl := trade.netPnl()
+		stats.Trades++
+		stats.NetPnl += pnl
+		totalHold += trade.holdTime()
+		if trade.Long {
+			stats.LongTrades++
+		} else {
+			stats.ShortTrades++
+		}
+		if pnl > 0 {
+			stats.Wins++
+			stats.GrossProfit += pnl
+		} else {
+			stats.GrossLoss -= pnl
+		}
+		if stats.Best == nil || pnl > stats.Best.netPnl() {
+			stats.Best = trade
+		}
+		if stats.Worst == nil || pnl < stats.Worst.netPnl() {
+			stats.Worst = trade
+		}
+		equity += pnl
+		peak = math.Max(peak, equity)
+		stats.MaxDrawdown = math.Max(stats.MaxDrawdown, peak-equity)
+	}
+	if stats.Trades == 0 {
+		return stats
+	}
+	stats.AvgHold = totalHold / time.Duration(stats.Trades)
+	stats.ProfitFactor = math.Inf(1)
+	if stats.GrossLoss > 0 {
+		stats.ProfitFactor = stats.GrossProfit / stats.GrossLoss
+	}
+	if losses := stats.Trades - stats.Wins; losses > 0 && stats.GrossLoss > 0 {
+		stats.AvgR = stats.NetPnl / float64(stats.Trades) / (stats.GrossLoss / float64(losses))
+		stats.HasR = true
+	}
+	return stats
+}
+
+func handleStatsCommand(chatID, msgText string) {
+	usage := "用法: /stats <地址|名称|#标签> [7d|30d|all]"
+	parts := strings.Fields(msgText)
+	if len(parts) < 2 {
+		sendMessage(chatID, usage)
+		return
+	}
+	period := "all"
+	selectorParts := parts[1:]
+	if last := selectorParts[len(selectorParts)-1]; len(selectorParts) > 1 && (last == "all" || historyPeriods[last] > 0) {
+		period = last
+		selectorParts = selectorParts[:len(selectorParts)-1]
+	}
+	query := strings.Join(selectorParts, " ")
+
+	targets, ok := statusTargets(chatID, query)
+	if !ok || len(targets) == 0 {
+		sendMessage(chatID, fmt.Sprintf("未找到名称或地址为 %s 的订阅。\n%s", query, usage))
+		return
+	}
+
+	go func() {
+		var since time.Time
+		if period != "all" {
+			since = time.Now().Add(-historyPeriods[period])
+		}
+		for _, wallet := range targets {
+			if err := syncTrades(wallet.Address); err != nil {
+				log.Printf("同步 %s 交易记录失败: %v", wallet.Address, err)
+			}
+			trades, err := loadTrades(strings.ToLower(wallet.Address), false, since)
+			if err != nil {
+				log.Printf("读取 %s 交易记录失败: %v", wallet.Address, err)
+				sendMessage(chatID, "读取交易记录失败，请稍后重试。")
+				return
+			}
+			sendMessage(chatID, formatTradeStats(wallet, period, computeTradeStats(trades)))
+		}
+	}()
+}
+
+func formatTradeStats(wallet WalletConfig, period string, stats tradeStats) string {
+	message := fmt.Sprintf("📐 HyperLiquid交易统计 - %s (%s)\n\n", wallet.Name, period)
+	message += fmt.Sprintf("💼 账户地址: %s\n\n", shortenAddress(wallet.Address))
+	if stats.Trades == 0 {
+		message += "该时间段内没有已完成的往返交易。"
+		if stats.OpenTrades > 0 {
+			message += fmt.Sprintf("\n未平仓交易: %d", stats.OpenTrades)
+		}
+		return message
+	}
+
+	message += fmt.Sprintf("🔁 已完成交易: %d (多 %d / 空 %d)，未平仓 %d\n", stats.Trades, stats.LongTrades, stats.ShortTrades, stats.OpenTrades)
+	message += fmt.Sprintf("🎯 胜率: %.1f%% (%d胜 %d负)\n", float64(stats.Wins)/float64(stats.Trades)*100, stats.Wins, stats.Trades-stats.Wins)
+	message += fmt.Sprintf("💰 净盈亏: $%+.2f (盈利 $%.2f / 亏损 $%.2f，已扣手续费)\n", stats.NetPnl, stats.GrossProfit, stats.GrossLoss)
+	if math.IsInf(stats.ProfitFactor, 1) {
+		message += "⚖️ 盈亏比 (Profit Factor): ∞\n"
+	} else {
+		message += fmt.Sprintf("⚖️ 盈亏比 (Profit Factor): %.2f\n", stats.ProfitFactor)
+	}
+	if stats.HasR {
+		message += fmt.Sprintf("📏 平均R: %+.2fR (1R = 平均亏损)\n", stats.AvgR)
+	}
+	message += fmt.Sprintf("⏱️ 平均持仓时间: %s\n", formatHoldTime(stats.AvgHold))
+	message += fmt.Sprintf("📉 最大回撤: $%.2f\n", stats.MaxDrawdown)
+	message += fmt.Sprintf("🏆 最佳交易: %s\n", formatTradeSummary(stats.Best))
+	message += fmt.Sprintf("💔 最差交易: %s", formatTradeSummary(stats.Worst))
+	return message
+}
+
+func formatTradeSummary(trade *Trade) string {
+	direction := "空头"
+	if trade.Long {
+		direction = "多头"
+	}
+	return fmt.Sprintf("%s %s $%+.2f (%s, 持仓 %s)", trade.Coin, direction, trade.netPnl(),
+		trade.CloseTime.Format("01-02 15:04"), formatHoldTime(trade.holdTime()))
+}
+
+func formatHoldTime(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%.1f天", d.Hours()/24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%.1f小时", d.Hours())
+	}
+	return fmt.Sprintf("%.0f分钟", d.Minutes())
+}
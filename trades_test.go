@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"position-monitor/hyperliquid"
+)
+
+func tradeFill(coin string, time, tid int64, side string, size, startPosition float64) hyperliquid.Fill {
+	return hyperliquid.Fill{Coin: coin, Px: "100", Sz: fmt.Sprint(size), Side: side, Time: time, Tid: tid,
+		StartPosition: fmt.Sprint(startPosition), Dir: "Open Long", ClosedPnl: "0", Fee: "0"}
+}
+
+// 分页边界落在同一毫秒中间时，交易重建不能丢失或重复成交
+func TestSyncTradesPagesWithinMillisecond(t *testing.T) {
+	setupTestDB(t)
+	address := "0x4444444444444444444444444444444444444444"
+
+	const adds = 2500
+	var fills []hyperliquid.Fill
+	tid := int64(0)
+	for i := 0; i < adds; i++ {
+		tid++
+		// 每 3 条成交共用一个毫秒
+		fills = append(fills, tradeFill("BTC", 1000+int64(i/3), tid, "B", 1, float64(i)))
+	}
+	tid++
+	fills = append(fills, tradeFill("BTC", 5000, tid, "A", adds, adds))
+	startFakeFillsServer(t, fills)
+
+	if err := syncTrades(address); err != nil {
+		t.Fatal(err)
+	}
+	trades, err := loadTrades(address, false, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("got %d trades, want 1", len(trades))
+	}
+	trade := trades[0]
+	if !trade.closed() || trade.Fills != adds+1 || trade.EntrySize != adds || trade.ExitSize != adds {
+		t.Errorf("trade = %+v", trade)
+	}
+
+	// 再次同步不应重复计入成交
+	if err := syncTrades(address); err != nil {
+		t.Fatal(err)
+	}
+	trades, _ = loadTrades(address, false, time.Time{})
+	if len(trades) != 1 || trades[0].Fills != adds+1 {
+		t.Errorf("second sync changed trades: %+v", trades[0])
+	}
+}
+
+// 按时间段查看统计时，早于该时间段开仓的未平仓交易仍应计入
+func TestStatsCountsOpenTradesInPeriod(t *testing.T) {
+	setupTestDB(t)
+	address := "0x5555555555555555555555555555555555555555"
+	now := time.Now()
+	old := now.Add(-30 * 24 * time.Hour).UnixMilli()
+	recent := now.Add(-time.Hour).UnixMilli()
+
+	startFakeFillsServer(t, []hyperliquid.Fill{
+		tradeFill("ETH", old, 1, "B", 1, 0),
+		tradeFill("BTC", old+1, 2, "B", 1, 0),
+		tradeFill("BTC", recent, 3, "A", 1, 1),
+	})
+	if err := syncTrades(address); err != nil {
+		t.Fatal(err)
+	}
+
+	trades, err := loadTrades(address, false, now.Add(-historyPeriods["7d"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := computeTradeStats(trades)
+	if stats.Trades != 1 || stats.OpenTrades != 1 {
+		t.Errorf("stats = %d closed / %d open, want 1 / 1", stats.Trades, stats.OpenTrades)
+	}
+	message := formatTradeStats(WalletConfig{Address: address, Name: "测试"}, "7d", stats)
+	if !strings.Contains(message, "未平仓 1") {
+		t.Errorf("message does not show open trade:\n%s", message)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ChannelWebhook = "webhook"
+
+	webhookSignatureHeader = "X-Position-Monitor-Signature"
+	webhookTimestampHeader = "X-Position-Monitor-Timestamp"
+	webhookDeliveryHeader  = "X-Position-Monitor-Delivery"
+
+	webhookMaxAttempts  = 6
+	webhookInitialDelay = 2 * time.Second
+)
+
+// WebhookEvent 是推送给外部系统的单个事件
+type WebhookEvent struct {
+	ID            string  `json:"id"`
+	Type          string  `json:"type"`
+	Address       string  `json:"address"`
+	Name          string  `json:"name"`
+	Coin          string  `json:"coin,omitempty"`
+	OldSize       float64 `json:"oldSize"`
+	NewSize       float64 `json:"newSize"`
+	ChangePercent float64 `json:"changePercent"`
+	OldValue      float64 `json:"oldValue,omitempty"`
+	NewValue      float64 `json:"newValue,omitempty"`
+	OldMode       string  `json:"oldMode,omitempty"`
+	NewMode       string  `json:"newMode,omitempty"`
+	EntryPx       float64 `json:"entryPx,omitempty"`
+	MarkPx        float64 `json:"markPx,omitempty"`
+	LiquidationPx float64 `json:"liquidationPx,omitempty"`
+	Text          string  `json:"text,omitempty"`
+	Timestamp     int64   `json:"timestamp"`
+}
+
+// WebhookNotifier 对每个事件发送签名的 JSON 请求，失败后指数退避重试，
+// 多次失败的投递写入死信表。target 为接收地址，签名密钥取自订阅配置。
+type WebhookNotifier struct {
+	HTTPClient *http.Client
+}
+
+func NewWebhookNotifier() *WebhookNotifier {
+	return &WebhookNotifier{HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *WebhookNotifier) Notify(target string, notification Notification) error {
+	events := webhookEvents(notification)
+	payloads := make([][]byte, len(events))
+	for i, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("转换JSON时出错: %v", err)
+		}
+		payloads[i] = payload
+	}
+	// 所有事件都投递成功后才算送达
+	var remaining atomic.Int64
+	remaining.Store(int64(len(events)))
+	delivered := func() {
+		if remaining.Add(-1) == 0 {
+			notification.sent()
+		}
+	}
+	for i, event := range events {
+		go w.deliver(target, notification.Wallet.Secret, event.ID, payloads[i], delivered)
+	}
+	return nil
+}
+
+func (WebhookNotifier) sendsInBackground() {}
+
+func (w *WebhookNotifier) deliver(url, secret, deliveryID string, payload []byte, delivered func()) {
+	delay := webhookInitialDelay
+	var lastErr error
+	attempts := 0
+	for attempts < webhookMaxAttempts {
+		attempts++
+		retryable, err := w.post(url, secret, deliveryID, payload)
+		if err == nil {
+			delivered()
+			return
+		}
+		lastErr = err
+		if !retryable {
+			break
+		}
+		if attempts < webhookMaxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	log.Printf("Webhook投递失败 %s (%s)，已写入死信表: %v", url, deliveryID, lastErr)
+	if err := saveWebhookDeadLetter(deliveryID, url, payload, lastErr, attempts); err != nil {
+		log.Printf("保存Webhook死信失败 %s: %v", deliveryID, err)
+	}
+}
+
+// 返回值表示失败后是否值得重试
+func (w *WebhookNotifier) post(url, secret, deliveryID string, payload []byte) (bool, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("创建请求时出错: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookDeliveryHeader, deliveryID)
+	req.Header.Set(webhookSignatureHeader, "sha256="+signWebhookPayload(secret, timestamp, payload))
+
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("发送请求时出错: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return retryable, fmt.Errorf("返回状态码 %d: %s", resp.StatusCode, string(body))
+}
+
+// 签名内容为 "时间戳.请求体"，接收方应同时校验时间戳防止重放
+func signWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookEvents(notification Notification) []WebhookEvent {
+	wallet := notification.Wallet
+
+	if notification.Changes == nil || notification.Changes.Empty() {
+		return []WebhookEvent{{
+			ID:        newDeliveryID(),
+			Type:      "message",
+			Address:   wallet.Address,
+			Name:      wallet.Name,
+			Text:      notification.Text,
+			Timestamp: time.Now().UnixMilli(),
+		}}
+	}
+
+	changes := notification.Changes
+	timestamp := changes.Time.UnixMilli()
+	var events []WebhookEvent
+	for _, event := range changes.PositionEvents {
+		entryPx, _ := strconv.ParseFloat(event.Position.EntryPx, 64)
+		liquidationPx, _ := strconv.ParseFloat(event.Position.LiquidationPx, 64)
+		events = append(events, WebhookEvent{
+			ID:            newDeliveryID(),
+			Type:          "position." + string(event.Type),
+			Address:       wallet.Address,
+			Name:          wallet.Name,
+			Coin:          event.Coin,
+			OldSize:       event.OldSize,
+			NewSize:       event.NewSize,
+			ChangePercent: event.ChangePercent,
+			OldValue:      event.OldValue,
+			NewValue:      event.NewValue,
+			OldMode:       event.OldMode,
+			NewMode:       event.NewMode,
+			EntryPx:       entryPx,
+			MarkPx:        positionPrice(event.Position),
+			LiquidationPx: liquidationPx,
+			Timestamp:     timestamp,
+		})
+	}
+	for _, event := range changes.AccountEvents {
+		events = append(events, WebhookEvent{
+			ID:            newDeliveryID(),
+			Type:          "account." + string(event.Type),
+			Address:       wallet.Address,
+			Name:          wallet.Name,
+			OldValue:      event.OldValue,
+			NewValue:      event.NewValue,
+			ChangePercent: event.ChangePercent,
+			Timestamp:     timestamp,
+		})
+	}
+	return events
+}
+
+func newDeliveryID() string {
+	return randomHex(16)
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+func saveWebhookDeadLetter(deliveryID, url string, payload []byte, lastErr error, attempts int) error {
+	errText := ""
+	if lastErr != nil {
+		errText = lastErr.Error()
+	}
+	_, err := db.Exec(`
+        INSERT OR REPLACE INTO webhook_dead_letters (delivery_id, url, payload, last_error, attempts, created_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `, deliveryID, url, string(payload), errText, attempts, time.Now().Unix())
+	return err
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 开仓的 oldSize 和平仓的 newSize 为 0 时也要输出，接收方才能区分"为零"和"不适用"
+func TestWebhookEventsKeepZeroSizes(t *testing.T) {
+	changes := Changes{Time: time.Now(), PositionEvents: []PositionEvent{
+		{Type: PositionOpen, Coin: "BTC", NewSize: 1},
+		{Type: PositionClose, Coin: "ETH", OldSize: -2, ChangePercent: 100},
+	}}
+	events := webhookEvents(Notification{Wallet: WalletConfig{Address: "0xabc", Name: "测试"}, Changes: &changes})
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+
+	tests := []struct {
+		event WebhookEvent
+		want  []string
+	}{
+		{events[0], []string{`"type":"position.open"`, `"oldSize":0`, `"newSize":1`, `"changePercent":0`}},
+		{events[1], []string{`"type":"position.close"`, `"oldSize":-2`, `"newSize":0`, `"changePercent":100`}},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s missing %s", data, want)
+			}
+		}
+	}
+}
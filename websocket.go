@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"position-monitor/hyperliquid"
+)
+
+// 让 WebSocket 订阅与当前订阅的地址保持一致
+//
+// 成交使用 userFills 而不是 userEvents：userEvents 的推送不带用户地址，同一连接订阅多个
+// 地址时无法判断推送属于哪个地址，userFills 的推送带有 user 字段。
+func syncWebSocketSubscriptions() {
+	addresses := subscribersByAddress()
+
+	for _, sub := range wsClient.Subscriptions() {
+		if _, exists := addresses[sub.User]; !exists {
+			if err := wsClient.Unsubscribe(sub); err != nil {
+				log.Printf("取消WebSocket订阅失败 %s: %v", sub.User, err)
+			}
+		}
+	}
+
+	for address := range addresses {
+		for _, subType := range []string{"webData2", "userFills"} {
+			sub := hyperliquid.Subscription{Type: subType, User: address}
+			if err := wsClient.Subscribe(sub); err != nil {
+				log.Printf("WebSocket订阅失败 %s (%s): %v", address, subType, err)
+			}
+		}
+	}
+}
+
+func handleWebSocketMessage(msg hyperliquid.WSMessage) {
+	switch msg.Channel {
+	case "webData2":
+		var data hyperliquid.WebData2
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			log.Printf("解析webData2失败: %v", err)
+			return
+		}
+		subscribers := subscribersForAddress(data.User)
+		if len(subscribers) == 0 {
+			return
+		}
+		positions, summary := parseClearinghouseState(&data.ClearinghouseState)
+		processAccountUpdate(subscribers[0].Address, subscribers, positions, summary)
+		checkLiquidationRisk(subscribers[0].Address, subscribers, positions)
+		if config.TrackOrders && data.OpenOrders != nil {
+			processOrderUpdate(subscribers[0].Address, subscribers, data.OpenOrders)
+		}
+
+	case "userFills":
+		var data hyperliquid.WSUserFills
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			log.Printf("解析userFills失败: %v", err)
+			return
+		}
+		subscribers := subscribersForAddress(data.User)
+		if len(subscribers) == 0 {
+			return
+		}
+		if config.NotifyFills {
+			handlePushedFills(subscribers[0].Address, subscribers, data.Fills, data.IsSnapshot)
+		}
+		// 快照是历史成交，只有新成交才需要立即检查持仓
+		if data.IsSnapshot || len(data.Fills) == 0 {
+			return
+		}
+		// 与轮询并发执行是安全的：processAccountUpdate 由 monitorMutex 串行化，账户状态按副本替换
+		go checkAddress(subscribers[0].Address, subscribers)
+	}
+}
+
+// 推送中的地址可能是小写，按不区分大小写匹配
+func subscribersForAddress(address string) []WalletConfig {
+	for addr, subscribers := range subscribersByAddress() {
+		if strings.EqualFold(addr, address) {
+			return subscribers
+		}
+	}
+	return nil
+}
+
+func checkAddress(address string, subscribers []WalletConfig) {
+	currentPositions, summary, err := fetchPositions(address)
+	if err != nil {
+		log.Printf("监控 %s 失败: %v", address, err)
+		return
+	}
+	processAccountUpdate(address, subscribers, currentPositions, summary)
+	checkLiquidationRisk(address, subscribers, currentPositions)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"position-monitor/hyperliquid"
+)
+
+const testChannel = "test"
+
+// recordingNotifier 记录收到的提醒
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+	err           error // 不为 nil 时发送失败，不记录提醒
+}
+
+func (n *recordingNotifier) Notify(target string, notification Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return n.err
+	}
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func (n *recordingNotifier) fail(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.err = err
+}
+
+func (n *recordingNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.notifications)
+}
+
+func registerTestNotifier(t *testing.T) *recordingNotifier {
+	notifier := &recordingNotifier{}
+	registerNotifier(testChannel, notifier)
+	t.Cleanup(func() { delete(notifiers, testChannel) })
+	return notifier
+}
+
+func clearinghouseStateJSON(szi float64, accountValue float64) hyperliquid.ClearinghouseState {
+	state := hyperliquid.ClearinghouseState{
+		MarginSummary: hyperliquid.MarginSummary{AccountValue: fmt.Sprint(accountValue), TotalMarginUsed: "100"},
+		Withdrawable:  "500",
+	}
+	if szi != 0 {
+		state.AssetPositions = []hyperliquid.AssetPosition{{Type: "oneWay", Position: hyperliquid.Position{
+			Coin: "BTC", Szi: fmt.Sprint(szi), PositionValue: fmt.Sprint(szi * 100), LiquidationPx: "50",
+			Leverage: hyperliquid.Leverage{Type: "cross", Value: 5},
+		}}}
+	}
+	return state
+}
+
+// fakeInfoServer 模拟轮询用到的 info 接口，每次请求的仓位大小都不同
+func fakeInfoServer(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Type string `json:"type"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		var response any
+		switch request.Type {
+		case "clearinghouseState":
+			n := calls.Add(1)
+			response = clearinghouseStateJSON(float64(n%4), 1000+float64(n))
+		case "frontendOpenOrders":
+			orders := []hyperliquid.OpenOrder{}
+			if calls.Load()%2 == 0 {
+				orders = append(orders, hyperliquid.OpenOrder{Coin: "BTC", Oid: 1, Side: "B", LimitPx: "90", Sz: "1", OrigSz: "1"})
+			}
+			response = orders
+		case "orderStatus":
+			response = map[string]string{"status": "unknownOid"}
+		case "metaAndAssetCtxs":
+			response = []any{map[string]any{"universe": []map[string]string{{"name": "BTC"}}}, []map[string]string{{"markPx": "100"}}}
+		default:
+			response = []any{}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	oldClient := hlClient
+	hlClient = hyperliquid.NewClient(hyperliquid.WithBaseURL(server.URL))
+	t.Cleanup(func() {
+		server.Close()
+		hlClient = oldClient
+	})
+}
+
+// WebSocket 推送和轮询同时处理同一地址时，账户状态不应出现数据竞争，用 go test -race 运行
+func TestWebSocketAndPollingConcurrent(t *testing.T) {
+	setupTestDB(t)
+	fakeInfoServer(t)
+	notifier := registerTestNotifier(t)
+	config.TrackOrders = true
+	config.LiquidationAlert = defaultLiquidationAlertConfig()
+
+	address := "0x2222222222222222222222222222222222222222"
+	walletMutex.Lock()
+	for _, chatID := range []string{"1", "2"} {
+		wallets[chatID+"_"+address] = WalletConfig{Address: address, Name: "测试" + chatID, ChatID: chatID, Channel: testChannel}
+	}
+	walletMutex.Unlock()
+
+	const rounds = 20
+	var wg sync.WaitGroup
+	wg.Add(2)
+	done := make(chan struct{})
+	readerDone := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			data, _ := json.Marshal(hyperliquid.WebData2{
+				User:               address,
+				ClearinghouseState: clearinghouseStateJSON(float64(-(i % 3)), 2000+float64(i)),
+				OpenOrders:         []hyperliquid.OpenOrder{},
+			})
+			handleWebSocketMessage(hyperliquid.WSMessage{Channel: "webData2", Data: data})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			monitorAllWallets()
+		}
+	}()
+	// 推送和轮询期间持续读取账户状态
+	go func() {
+		defer close(readerDone)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			walletMutex.Lock()
+			wallet := wallets["1_"+address]
+			walletMutex.Unlock()
+			emailAccounts([]WalletConfig{wallet})
+		}
+	}()
+	wg.Wait()
+	close(done)
+	<-readerDone
+
+	if notifier.count() == 0 {
+		t.Fatalf("no notifications were sent")
+	}
+	walletMutex.Lock()
+	defer walletMutex.Unlock()
+	if _, exists := accountStates[address]; !exists {
+		t.Errorf("address state missing")
+	}
+	for _, chatID := range []string{"1", "2"} {
+		if _, exists := subscriptionStates[chatID+"_"+address]; !exists {
+			t.Errorf("subscription state missing for chat %s", chatID)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ChannelWeCom = "wecom"
+
+	weComWebhookPrefix = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key="
+	weComMaxBytes      = 4096 // Markdown 内容上限按 UTF-8 字节计算
+)
+
+type weComMarkdown struct {
+	Content string `json:"content"`
+}
+
+type weComPayload struct {
+	MsgType  string        `json:"msgtype"`
+	Markdown weComMarkdown `json:"markdown"`
+}
+
+// WeComNotifier 通过企业微信群机器人发送 Markdown 消息，target 为 Webhook 地址
+type WeComNotifier struct {
+	HTTPClient *http.Client
+}
+
+func NewWeComNotifier() *WeComNotifier {
+	return &WeComNotifier{HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// 内容超过单条上限时按段拆成多条消息发送
+func (w *WeComNotifier) Notify(target string, notification Notification) error {
+	for _, content := range weComMessages(notification) {
+		payload := weComPayload{MsgType: "markdown", Markdown: weComMarkdown{Content: content}}
+		if err := postBotMessage(w.HTTPClient, target, payload, "企业微信"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func weComMessages(notification Notification) []string {
+	wallet := notification.Wallet
+	title := notification.Title
+	if title == "" {
+		title = "HyperLiquid提醒"
+	}
+	header := fmt.Sprintf("### %s\n> 账户: <font color=\"comment\">%s (%s)</font>\n", title, wallet.Name, shortenAddress(wallet.Address))
+
+	var messages []string
+	current := header
+	for _, section := range markdownSections(notification) {
+		block := "\n" + weComHeading(section) + "\n" + strings.Join(section.Lines, "\n") + "\n"
+		if len(current)+len(block) > weComMaxBytes && current != header {
+			messages = append(messages, truncateBytes(current, weComMaxBytes))
+			current = header
+		}
+		// 单段超过上限时独占一条消息，发送前截断
+		current += block
+	}
+	return append(messages, truncateBytes(current, weComMaxBytes))
+}
+
+// 企业微信只支持 info（绿）、warning（橙）和 comment（灰）三种颜色
+func weComHeading(section markdownSection) string {
+	heading := "**" + section.Title + "**"
+	switch section.Color {
+	case discordColorGreen:
+		return `<font color="info">` + heading + "</font>"
+	case discordColorRed, discordColorOrange:
+		return `<font color="warning">` + heading + "</font>"
+	}
+	return heading
+}
+
+// 按字节截断，并保证不截断多字节字符
+func truncateBytes(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	cut := limit - len("…")
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…"
+}
+
+func isValidWeComWebhook(url string) bool {
+	return strings.HasPrefix(url, weComWebhookPrefix)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// 每条消息都不能超过企业微信的字节上限，包括单行超长的中间消息
+func TestWeComMessagesRespectByteLimit(t *testing.T) {
+	long := strings.Repeat("仓位", weComMaxBytes/3)
+	text := "第一段\n" + long + "\n\n第二段\n" + strings.Repeat("明细\n", 10)
+	notification := Notification{
+		Wallet: WalletConfig{Address: "0x3333333333333333333333333333333333333333", Name: "测试"},
+		Title:  "HyperLiquid提醒",
+		Text:   text,
+	}
+	// 按事件分段时第一段只有一行，但长度超过上限
+	changes := Changes{PositionEvents: []PositionEvent{{Type: PositionOpen, Coin: long, NewSize: 1}, {Type: PositionOpen, Coin: "ETH", NewSize: 1}}}
+
+	for name, n := range map[string]Notification{"text": notification, "events": {Wallet: notification.Wallet, Changes: &changes}} {
+		messages := weComMessages(n)
+		if len(messages) < 2 && name == "events" {
+			t.Errorf("%s: got %d messages, want the long section on its own", name, len(messages))
+		}
+		for i, message := range messages {
+			if len(message) > weComMaxBytes {
+				t.Errorf("%s: message %d is %d bytes, limit %d", name, i, len(message), weComMaxBytes)
+			}
+			if !utf8.ValidString(message) {
+				t.Errorf("%s: message %d cut a multi-byte character", name, i)
+			}
+		}
+	}
+}